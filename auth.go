@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	comm "libcommunicator"
+)
+
+// tokenCommandTimeout bounds a token command, which may wait on a browser
+// or a hardware key for the SSO login
+const tokenCommandTimeout = 2 * time.Minute
+
+// reauthMsg reports a re-authentication: started, or done with err
+type reauthMsg struct {
+	done bool
+	err  error
+}
+
+// runTokenCommand runs command with the shell and returns the token it
+// prints
+func runTokenCommand(command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), tokenCommandTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "sh", "-c", command).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			err = fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("token command failed: %w", err)
+	}
+	token := strings.TrimSpace(string(out))
+	if token == "" {
+		return "", errors.New("token command printed no token")
+	}
+	return token, nil
+}
+
+// unauthorized reports whether err says the session is no longer valid
+func unauthorized(err error) bool {
+	return httpStatus(err) == 401
+}
+
+// reauthPlatform is a platform that logs in again when the session
+// expires mid-session, with a fresh token from the token command or with
+// the password, and then retries the failed call once. Without a way to
+// log in again it passes errors through.
+type reauthPlatform struct {
+	*comm.Platform
+	cfg    config
+	status chan reauthMsg // to the UI, see waitForReauth
+
+	mu         sync.Mutex
+	generation int // logins so far, so concurrent failures log in once
+}
+
+// newReauthPlatform wraps platform, logged in with cfg
+func newReauthPlatform(platform *comm.Platform, cfg config) *reauthPlatform {
+	return &reauthPlatform{Platform: platform, cfg: cfg, status: make(chan reauthMsg, 4)}
+}
+
+// canReauth reports whether there is a way to log in again
+func (p *reauthPlatform) canReauth() bool {
+	return p.cfg.tokenCommand != "" || (p.cfg.loginID != "" && p.cfg.password != "")
+}
+
+// report tells the UI how re-authenticating goes, if it is listening
+func (p *reauthPlatform) report(msg reauthMsg) {
+	select {
+	case p.status <- msg:
+	default:
+	}
+}
+
+// reauth logs in again unless another call already did since seen
+func (p *reauthPlatform) reauth(seen int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.generation != seen {
+		return nil
+	}
+	log.Printf("session expired, re-authenticating")
+	p.report(reauthMsg{})
+
+	token := ""
+	if p.cfg.tokenCommand != "" {
+		var err error
+		if token, err = runTokenCommand(p.cfg.tokenCommand); err != nil {
+			p.report(reauthMsg{done: true, err: err})
+			return err
+		}
+	}
+	err := p.Platform.Connect(platformConfig(p.cfg, token))
+	p.report(reauthMsg{done: true, err: err})
+	if err != nil {
+		return fmt.Errorf("re-authenticate: %w", err)
+	}
+	p.generation++
+	return nil
+}
+
+// retry runs call and, if it fails for want of a session, logs in again
+// and runs it once more. Errors come back as platformErrors.
+func retry[T any](p *reauthPlatform, call func() (T, error)) (T, error) {
+	p.mu.Lock()
+	seen := p.generation
+	p.mu.Unlock()
+
+	v, err := call()
+	if err == nil {
+		return v, nil
+	}
+	if !unauthorized(err) || !p.canReauth() {
+		return v, &platformError{err}
+	}
+	if rerr := p.reauth(seen); rerr != nil {
+		log.Printf("%v", rerr)
+		return v, &platformError{err}
+	}
+	if v, err = call(); err != nil {
+		return v, &platformError{err}
+	}
+	return v, nil
+}
+
+// retryErr is retry for calls that return only an error
+func retryErr(p *reauthPlatform, call func() error) error {
+	_, err := retry(p, func() (struct{}, error) { return struct{}{}, call() })
+	return err
+}
+
+func (p *reauthPlatform) SetTeamID(teamID string) error {
+	return retryErr(p, func() error { return p.Platform.SetTeamID(teamID) })
+}
+
+func (p *reauthPlatform) GetTeams() ([]comm.Team, error) {
+	return retry(p, p.Platform.GetTeams)
+}
+
+func (p *reauthPlatform) GetChannels() ([]comm.Channel, error) {
+	return retry(p, p.Platform.GetChannels)
+}
+
+func (p *reauthPlatform) GetChannelMembers(channelID string) ([]comm.User, error) {
+	return retry(p, func() ([]comm.User, error) { return p.Platform.GetChannelMembers(channelID) })
+}
+
+func (p *reauthPlatform) GetChannelStats(channelID string) (*comm.ChannelStats, error) {
+	return retry(p, func() (*comm.ChannelStats, error) { return p.Platform.GetChannelStats(channelID) })
+}
+
+func (p *reauthPlatform) MarkChannelRead(channelID string) error {
+	return retryErr(p, func() error { return p.Platform.MarkChannelRead(channelID) })
+}
+
+func (p *reauthPlatform) GetMessages(channelID string, limit int) ([]comm.Message, error) {
+	return retry(p, func() ([]comm.Message, error) { return p.Platform.GetMessages(channelID, limit) })
+}
+
+func (p *reauthPlatform) GetMessagesBefore(channelID, beforeID string, limit int) ([]comm.Message, error) {
+	return retry(p, func() ([]comm.Message, error) { return p.Platform.GetMessagesBefore(channelID, beforeID, limit) })
+}
+
+func (p *reauthPlatform) GetMessage(messageID string) (*comm.Message, error) {
+	return retry(p, func() (*comm.Message, error) { return p.Platform.GetMessage(messageID) })
+}
+
+func (p *reauthPlatform) SearchMessages(teamID, query string) ([]comm.Message, error) {
+	return retry(p, func() ([]comm.Message, error) { return p.Platform.SearchMessages(teamID, query) })
+}
+
+func (p *reauthPlatform) GetPinnedMessages(channelID string) ([]comm.Message, error) {
+	return retry(p, func() ([]comm.Message, error) { return p.Platform.GetPinnedMessages(channelID) })
+}
+
+func (p *reauthPlatform) SendMessage(channelID, text string) (*comm.Message, error) {
+	return retry(p, func() (*comm.Message, error) { return p.Platform.SendMessage(channelID, text) })
+}
+
+func (p *reauthPlatform) SendReply(channelID, rootID, text string) (*comm.Message, error) {
+	return retry(p, func() (*comm.Message, error) { return p.Platform.SendReply(channelID, rootID, text) })
+}
+
+func (p *reauthPlatform) EditMessage(messageID, text string) (*comm.Message, error) {
+	return retry(p, func() (*comm.Message, error) { return p.Platform.EditMessage(messageID, text) })
+}
+
+func (p *reauthPlatform) ExecuteCommand(channelID, command string) (string, error) {
+	return retry(p, func() (string, error) { return p.Platform.ExecuteCommand(channelID, command) })
+}
+
+func (p *reauthPlatform) AddReaction(messageID, emojiName string) error {
+	return retryErr(p, func() error { return p.Platform.AddReaction(messageID, emojiName) })
+}
+
+func (p *reauthPlatform) RemoveReaction(messageID, emojiName string) error {
+	return retryErr(p, func() error { return p.Platform.RemoveReaction(messageID, emojiName) })
+}
+
+func (p *reauthPlatform) SendTyping(channelID string) error {
+	return retryErr(p, func() error { return p.Platform.SendTyping(channelID) })
+}
+
+func (p *reauthPlatform) GetUser(userID string) (*comm.User, error) {
+	return retry(p, func() (*comm.User, error) { return p.Platform.GetUser(userID) })
+}
+
+func (p *reauthPlatform) DownloadFile(fileID string) ([]byte, error) {
+	return retry(p, func() ([]byte, error) { return p.Platform.DownloadFile(fileID) })
+}
+
+func (p *reauthPlatform) GetUserStatus(userID string) (string, error) {
+	return retry(p, func() (string, error) { return p.Platform.GetUserStatus(userID) })
+}
+
+func (p *reauthPlatform) SearchUsers(query string) ([]comm.User, error) {
+	return retry(p, func() ([]comm.User, error) { return p.Platform.SearchUsers(query) })
+}
+
+func (p *reauthPlatform) NewEventStream(ctx context.Context, bufferSize int, debounce time.Duration) (*comm.EventStream, error) {
+	return retry(p, func() (*comm.EventStream, error) { return p.Platform.NewEventStream(ctx, bufferSize, debounce) })
+}
+
+var _ platformAPI = (*reauthPlatform)(nil)
+
+// waitForReauth waits for the next re-authentication report
+func waitForReauth(status <-chan reauthMsg) tea.Cmd {
+	if status == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		return <-status
+	}
+}
+
+// handleReauth shows how re-authenticating goes in the status bar,
+// reporting whether msg was about it
+func (m model) handleReauth(msg tea.Msg) (tea.Model, tea.Cmd, bool) {
+	r, ok := msg.(reauthMsg)
+	if !ok {
+		return m, nil, false
+	}
+	switch {
+	case !r.done:
+		m.notice = "session expired, re-authenticating…"
+	case r.err != nil:
+		m.notice = ""
+		m.reportError("re-authenticate", r.err)
+	default:
+		m.notice = "re-authenticated"
+	}
+	return m, waitForReauth(m.reauthStatus), true
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	comm "libcommunicator"
+)
+
+// authorFoundMsg carries the user a server search found for the author
+// prompt, or nil if none has that username
+type authorFoundMsg struct {
+	name string
+	user *comm.User
+}
+
+// findAuthor asks the server for the user named name, for a filter on a
+// username not in the cache
+func findAuthor(platform platformAPI, name string) tea.Cmd {
+	return func() tea.Msg {
+		users, err := platform.SearchUsers(name)
+		if err != nil {
+			return errMsg(&opError{op: "find user", err: err})
+		}
+		for i := range users {
+			if strings.EqualFold(users[i].Username, name) {
+				return authorFoundMsg{name: name, user: &users[i]}
+			}
+		}
+		return authorFoundMsg{name: name}
+	}
+}
+
+// userByName returns the cached user with username name, ignoring case
+func (m model) userByName(name string) *comm.User {
+	for _, u := range m.users {
+		if u != nil && strings.EqualFold(u.Username, name) {
+			return u
+		}
+	}
+	return nil
+}
+
+// handleAuthorKeys handles the author filter key, which opens the prompt
+// (filled in with the selected message's author), the prompt itself, and
+// esc, which clears the filter
+func (m model) handleAuthorKeys(key string) (tea.Model, tea.Cmd, bool) {
+	if !m.mainFocused() {
+		return m, nil, false
+	}
+	if !m.authorPrompt {
+		switch {
+		case keys.action(ctxMain, key) == actAuthor:
+			m.authorPrompt = true
+			m.authorInput = ""
+			if display := m.getDisplayMessages(); m.messageCursor >= 0 && m.messageCursor < len(display) {
+				m.authorInput = m.nick(display[m.messageCursor].SenderID)
+			}
+			return m, nil, true
+		case keys.action(ctxGeneral, key) == actCancel && m.authorFilter != "" && m.editingMessageID == "":
+			m.setAuthor("", "")
+			m.notice = "showing everyone"
+			return m, nil, true
+		}
+		return m, nil, false
+	}
+
+	// The prompt takes every key until enter or esc
+	switch {
+	case keys.action(ctxGeneral, key) == actCancel:
+		m.authorPrompt = false
+	case keys.action(ctxPrompt, key) == actAccept:
+		m.authorPrompt = false
+		name := strings.TrimPrefix(strings.TrimSpace(m.authorInput), "@")
+		if name == "" {
+			return m, m.setAuthor("", ""), true
+		}
+		if u := m.userByName(name); u != nil {
+			return m, m.setAuthor(u.ID, u.Username), true
+		}
+		m.notice = fmt.Sprintf("looking up %s…", name)
+		return m, findAuthor(m.platform, name), true
+	case keys.action(ctxInput, key) == actDeleteBack:
+		if r := []rune(m.authorInput); len(r) > 0 {
+			m.authorInput = string(r[:len(r)-1])
+		}
+	case printableKey(key):
+		m.authorInput += key
+	}
+	return m, nil, true
+}
+
+// handleAuthorFound filters on the user the server found
+func (m *model) handleAuthorFound(msg authorFoundMsg) tea.Cmd {
+	if msg.user == nil {
+		m.notice = fmt.Sprintf("no user named %s", msg.name)
+		return nil
+	}
+	m.addUsers([]comm.User{*msg.user})
+	return m.setAuthor(msg.user.ID, msg.user.Username)
+}
+
+// setAuthor shows only the messages userID sent, or everyone's if userID
+// is "". The view goes back to the newest message, since the old scroll
+// position means nothing in the other set. If none of theirs is loaded,
+// older pages are fetched until one is, as for a page of only replies.
+func (m *model) setAuthor(userID, name string) tea.Cmd {
+	m.authorFilter = userID
+	m.authorName = name
+	m.displayMsgsDirty = true
+	m.scrollOffset = 0
+	m.messageCursor = -1
+	m.notice = ""
+	if userID == "" || len(m.getDisplayMessages()) > 0 || m.threadRootID != "" {
+		return nil
+	}
+	m.notice = fmt.Sprintf("no messages from %s loaded; looking further back…", name)
+	return m.loadOlder()
+}
+
+// filterAuthor keeps the messages the author filter lets through
+func (m model) filterAuthor(msgs []comm.Message) []comm.Message {
+	if m.authorFilter == "" {
+		return msgs
+	}
+	kept := msgs[:0:0]
+	for _, msg := range msgs {
+		if msg.SenderID == m.authorFilter {
+			kept = append(kept, msg)
+		}
+	}
+	return kept
+}
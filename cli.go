@@ -0,0 +1,433 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	comm "libcommunicator"
+
+	conf "termunicator/internal/config"
+)
+
+// connFlags are the connection flags shared by the TUI and subcommands.
+// They win over MATTERMOST_* variables and config.toml.
+type connFlags struct {
+	host, scheme, token, tokenCmd, user, pass, teamID, profile *string
+	insecure                                                   *bool
+}
+
+func addConnFlags(fs *flag.FlagSet) connFlags {
+	return connFlags{
+		host:     fs.String("host", "", "Mattermost server host, optionally with port or scheme (e.g., chat.example.com, localhost:8065)"),
+		scheme:   fs.String("scheme", "", "URL scheme when -host has none: https (default) or http"),
+		token:    fs.String("token", "", "Personal Access Token"),
+		tokenCmd: fs.String("token-cmd", "", "Shell command printing a token, run again when the session expires (SSO)"),
+		user:     fs.String("user", "", "Username or email for login"),
+		pass:     fs.String("pass", "", "Password for login"),
+		teamID:   fs.String("teamid", "", "Team ID (optional)"),
+		profile:  fs.String("profile", "", "Server profile from config.toml (default: the file's \"profile\" setting); ignores MATTERMOST_* variables"),
+		insecure: fs.Bool("insecure", false, "Skip TLS certificate verification (self-signed servers; unsafe)"),
+	}
+}
+
+// resolve layers the flags over the environment and config file and
+// checks the result is enough to connect
+func (f connFlags) resolve() (config, error) {
+	file, err := conf.Load()
+	if err != nil {
+		return config{}, err
+	}
+	mm, err := file.Profile(*f.profile)
+	if err != nil {
+		return config{}, err
+	}
+	for _, o := range []struct{ flag, field *string }{
+		{f.host, &mm.Host},
+		{f.scheme, &mm.Scheme},
+		{f.token, &mm.Token},
+		{f.tokenCmd, &mm.TokenCommand},
+		{f.user, &mm.LoginID},
+		{f.pass, &mm.Password},
+		{f.teamID, &mm.TeamID},
+	} {
+		if *o.flag != "" {
+			*o.field = *o.flag
+		}
+	}
+	if err := mm.Validate(); err != nil {
+		return config{}, err
+	}
+	urlWidth := -1 // the default
+	if file.URLWidth != nil {
+		urlWidth = *file.URLWidth
+	}
+	showReplies := file.ShowReplies != nil && *file.ShowReplies
+	return config{
+		host:         mm.Host,
+		scheme:       mm.Scheme,
+		token:        mm.Token,
+		tokenCommand: mm.TokenCommand,
+		loginID:      mm.LoginID,
+		password:     mm.Password,
+		teamID:       mm.TeamID,
+		insecure:     *f.insecure,
+		timeFormat:   file.TimeFormat,
+		downloadDir:  file.DownloadDir,
+		urlWidth:     urlWidth,
+		showReplies:  showReplies,
+		theme:        file.Theme,
+		keys:         file.Keys,
+	}, nil
+}
+
+// subcommand is a non-interactive mode, run as "termunicator NAME ..."
+type subcommand struct {
+	name string
+	desc string
+	run  func(args []string) error
+}
+
+// Pike/Cox: a table, so usage lists every subcommand
+var subcommands = []subcommand{
+	{"send", "Send a message and print its ID", runSend},
+	{"tail", "Print a channel's new messages as they arrive", runTail},
+	{"list", "List teams or channels (list teams | list channels)", runList},
+	{"search", "Search a team's messages on the server", runSearch},
+	{"export", "Write a channel's whole history to a file", runExport},
+}
+
+// runSubcommand runs the subcommand named by args[0], if there is one,
+// and reports whether it did. Failures exit non-zero.
+func runSubcommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	for _, sc := range subcommands {
+		if sc.name != args[0] {
+			continue
+		}
+		log.SetOutput(io.Discard)
+		if err := sc.run(args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "termunicator %s: %v\n", sc.name, err)
+			os.Exit(1)
+		}
+		return true
+	}
+	return false
+}
+
+// printSubcommands writes the subcommand list for flag.Usage
+func printSubcommands(w io.Writer) {
+	for _, sc := range subcommands {
+		fmt.Fprintf(w, "  %-8s %s\n", sc.name, sc.desc)
+	}
+}
+
+// connectFlags resolves the connection settings and logs in. The returned
+// func disconnects and cleans up.
+func connectFlags(f connFlags) (*comm.Platform, func(), error) {
+	cfg, err := f.resolve()
+	if err != nil {
+		return nil, nil, err
+	}
+	platform, err := connect(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return platform, func() { disconnect(platform) }, nil
+}
+
+// disconnect logs out and releases the library
+func disconnect(platform *comm.Platform) {
+	platform.Disconnect()
+	platform.Destroy()
+	comm.Cleanup()
+}
+
+// runSend posts a message: the arguments joined by spaces, or stdin if
+// there are none
+func runSend(args []string) error {
+	fs := flag.NewFlagSet("send", flag.ExitOnError)
+	cf := addConnFlags(fs)
+	channel := fs.String("channel", "", "Channel ID to post in (required)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: termunicator send -channel ID [connection flags] [message...]\n\n")
+		fmt.Fprintf(os.Stderr, "With no message arguments the message is read from stdin.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *channel == "" {
+		return errors.New("-channel is required")
+	}
+
+	text := strings.Join(fs.Args(), " ")
+	if text == "" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("read message: %w", err)
+		}
+		text = strings.TrimRight(string(data), "\n")
+	}
+	if strings.TrimSpace(text) == "" {
+		return errors.New("empty message")
+	}
+
+	platform, done, err := connectFlags(cf)
+	if err != nil {
+		return err
+	}
+	defer done()
+	msg, err := platform.SendMessage(*channel, text)
+	if err != nil {
+		return fmt.Errorf("send message: %w", err)
+	}
+	fmt.Println(msg.ID)
+	return nil
+}
+
+// tailLine is one message as printed by tail -json
+type tailLine struct {
+	ID        string    `json:"id"`
+	ChannelID string    `json:"channel_id"`
+	UserID    string    `json:"user_id"`
+	Username  string    `json:"username"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// runTail prints messages posted to a channel until interrupted, as
+// "HH:MM <nick> text" lines or, with -json, one JSON object per line
+func runTail(args []string) error {
+	fs := flag.NewFlagSet("tail", flag.ExitOnError)
+	cf := addConnFlags(fs)
+	channel := fs.String("channel", "", "Channel ID to follow (required)")
+	asJSON := fs.Bool("json", false, "Print one JSON object per message")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: termunicator tail -channel ID [-json] [connection flags]\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *channel == "" {
+		return errors.New("-channel is required")
+	}
+
+	platform, done, err := connectFlags(cf)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	stream, err := platform.NewEventStream(ctx, eventStreamBufferSize, eventStreamDebounceDelay)
+	if err != nil {
+		return fmt.Errorf("create event stream failed: %w", err)
+	}
+	// Closing the stream on Ctrl+C ends the loop below
+	go func() {
+		<-ctx.Done()
+		stream.Close()
+	}()
+
+	nick := nicknames(platform)
+
+	enc := json.NewEncoder(os.Stdout)
+	wait := waitForEvent(stream)
+	for {
+		switch msg := wait().(type) {
+		case streamLostMsg:
+			if ctx.Err() != nil {
+				return nil // interrupted
+			}
+			return fmt.Errorf("event stream: %w", msg.err)
+		case eventMsg:
+			event := (*comm.Event)(msg)
+			if event.Type != comm.EventMessagePosted || event.ChannelID != *channel {
+				continue
+			}
+			id := eventMessageID(event)
+			if id == "" {
+				continue
+			}
+			switch posted := fetchMessage(platform, id)().(type) {
+			case newMessageMsg:
+				m := comm.Message(posted)
+				if *asJSON {
+					enc.Encode(tailLine{m.ID, m.ChannelID, m.SenderID, nick(m.SenderID), m.Text, m.CreatedAt})
+				} else {
+					text := strings.ReplaceAll(m.Text, "\n", "↵")
+					fmt.Printf("%s <%s> %s\n", m.CreatedAt.Format("15:04"), nick(m.SenderID), text)
+				}
+			case errMsg:
+				fmt.Fprintf(os.Stderr, "termunicator tail: %v\n", posted)
+			}
+		}
+	}
+}
+
+// runSearch prints the messages in a team matching a query, newest
+// first, as "YYYY-MM-DD HH:MM channel <nick> text" lines or JSON lines
+func runSearch(args []string) error {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	cf := addConnFlags(fs)
+	limit := fs.Int("limit", 20, "Print at most this many results (0 = all)")
+	asJSON := fs.Bool("json", false, "Print one JSON object per message")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: termunicator search [-limit N] [-json] [connection flags] query...\n\n")
+		fmt.Fprintf(os.Stderr, "The query uses Mattermost search syntax (from:, in:, \"phrases\"...).\n")
+		fmt.Fprintf(os.Stderr, "Searching needs a team: -teamid, or team_id in config.toml.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	query := strings.Join(fs.Args(), " ")
+	if strings.TrimSpace(query) == "" {
+		fs.Usage()
+		return errors.New("say what to search for")
+	}
+	cfg, err := cf.resolve()
+	if err != nil {
+		return err
+	}
+	if cfg.teamID == "" {
+		return errors.New("-teamid is required to search (see: termunicator list teams)")
+	}
+
+	platform, err := connect(cfg)
+	if err != nil {
+		return err
+	}
+	defer disconnect(platform)
+
+	results, err := platform.SearchMessages(cfg.teamID, query)
+	if err != nil {
+		return fmt.Errorf("search failed: %w", err)
+	}
+	if len(results) == 0 {
+		fmt.Fprintf(os.Stderr, "no messages match %q\n", query)
+		return nil
+	}
+	sortNewestFirst(results)
+	if *limit > 0 && len(results) > *limit {
+		results = results[:*limit]
+	}
+
+	// Channel and user names
+	channels := make(map[string]string)
+	if list, err := platform.GetChannels(); err == nil {
+		for _, ch := range list {
+			channels[ch.ID] = channelName(ch)
+		}
+	}
+	nick := nicknames(platform)
+
+	enc := json.NewEncoder(os.Stdout)
+	for _, m := range results {
+		if *asJSON {
+			enc.Encode(tailLine{m.ID, m.ChannelID, m.SenderID, nick(m.SenderID), m.Text, m.CreatedAt})
+			continue
+		}
+		channel, ok := channels[m.ChannelID]
+		if !ok {
+			channel = m.ChannelID
+		}
+		text := strings.ReplaceAll(m.Text, "\n", "↵")
+		fmt.Printf("%s %s <%s> %s\n", m.CreatedAt.Local().Format("2006-01-02 15:04"), channel, nick(m.SenderID), text)
+	}
+	return nil
+}
+
+// nicknames returns a func looking up usernames by user ID, each once.
+// Unknown users are shown by ID.
+func nicknames(platform *comm.Platform) func(userID string) string {
+	nicks := make(map[string]string)
+	return func(userID string) string {
+		if name, ok := nicks[userID]; ok {
+			return name
+		}
+		name := userID
+		if user, err := platform.GetUser(userID); err == nil && user != nil && user.Username != "" {
+			name = user.Username
+		}
+		nicks[userID] = name
+		return name
+	}
+}
+
+// runList prints the teams, or the channels of a team, so their IDs can be
+// used with -teamid and -channel
+func runList(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	cf := addConnFlags(fs)
+	format := fs.String("format", "table", "Output format: table or json")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: termunicator list [-format table|json] [connection flags] teams|channels\n\n")
+		fmt.Fprintf(os.Stderr, "Listing channels needs a team: -teamid, or team_id in config.toml.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 || (fs.Arg(0) != "teams" && fs.Arg(0) != "channels") {
+		fs.Usage()
+		return errors.New("say what to list: teams or channels")
+	}
+	if *format != "table" && *format != "json" {
+		return fmt.Errorf("unknown -format %q (want table or json)", *format)
+	}
+	cfg, err := cf.resolve()
+	if err != nil {
+		return err
+	}
+	if fs.Arg(0) == "channels" && cfg.teamID == "" {
+		return errors.New("-teamid is required to list channels (see: termunicator list teams)")
+	}
+
+	platform, err := connect(cfg)
+	if err != nil {
+		return err
+	}
+	defer disconnect(platform)
+
+	// rows are ID, name, type for the table
+	var items interface{}
+	var rows [][3]string
+	if fs.Arg(0) == "teams" {
+		teams, err := platform.GetTeams()
+		if err != nil {
+			return fmt.Errorf("get teams failed: %w", err)
+		}
+		items = append([]comm.Team{}, teams...) // [] rather than null when empty
+		for _, t := range teams {
+			rows = append(rows, [3]string{t.ID, t.Name, "team"})
+		}
+	} else {
+		channels, err := platform.GetChannels()
+		if err != nil {
+			return fmt.Errorf("get channels failed: %w", err)
+		}
+		items = append([]comm.Channel{}, channels...)
+		for _, ch := range channels {
+			rows = append(rows, [3]string{ch.ID, ch.Name, fmt.Sprint(ch.Type)})
+		}
+	}
+
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(items)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tTYPE")
+	for _, r := range rows {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", r[0], r[1], r[2])
+	}
+	return tw.Flush()
+}
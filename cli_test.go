@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolvePrecedence(t *testing.T) {
+	const file = `
+[mattermost]
+host = "default.example.com"
+token = "file-token"
+team_id = "file-team"
+`
+	tests := []struct {
+		name string
+		env  string // MATTERMOST_TOKEN
+		args []string
+		want string // token
+	}{
+		{"file", "", nil, "file-token"},
+		{"environment over file", "env-token", nil, "env-token"},
+		{"flag over environment", "env-token", []string{"-token", "flag-token"}, "flag-token"},
+		{"flag over file", "", []string{"-token", "flag-token"}, "flag-token"},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		t.Setenv("XDG_CONFIG_HOME", dir)
+		t.Setenv("HOME", dir)
+		for _, v := range []string{"MATTERMOST_HOST", "MATTERMOST_TOKEN_COMMAND", "MATTERMOST_LOGIN_ID", "MATTERMOST_TEAM_ID"} {
+			t.Setenv(v, "")
+		}
+		t.Setenv("MATTERMOST_TOKEN", tt.env)
+		path := filepath.Join(dir, "termunicator", "config.toml")
+		os.MkdirAll(filepath.Dir(path), 0700)
+		if err := os.WriteFile(path, []byte(file), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		f := addConnFlags(fs)
+		if err := fs.Parse(tt.args); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := f.resolve()
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if cfg.token != tt.want || cfg.host != "default.example.com" || cfg.teamID != "file-team" {
+			t.Errorf("%s: token %q, host %q, team %q; want %q with the file's host and team", tt.name, cfg.token, cfg.host, cfg.teamID, tt.want)
+		}
+	}
+}
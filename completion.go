@@ -0,0 +1,177 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	comm "libcommunicator"
+)
+
+// maxSuggestions caps the completion popup above the input line
+const maxSuggestions = 5
+
+type usersFoundMsg struct {
+	query string
+	users []comm.User
+}
+
+// completionTriggers start a completable word: @user, and ~channel or
+// #channel (Mattermost links ~channel)
+const completionTriggers = "@~#"
+
+// completionToken returns the word before the cursor, where it starts and
+// its trigger, if it begins with one of completionTriggers. Words are
+// separated by whitespace, so "@bo" completes but "mail@bo" does not.
+func completionToken(input string, cursor int) (int, rune, string, bool) {
+	runes := []rune(input)
+	cursor = min(cursor, len(runes))
+	start := cursor
+	for start > 0 && !unicode.IsSpace(runes[start-1]) {
+		start--
+	}
+	if start == cursor || !strings.ContainsRune(completionTriggers, runes[start]) {
+		return 0, 0, "", false
+	}
+	return start, runes[start], string(runes[start+1 : cursor]), true
+}
+
+// refreshCompletion recomputes the popup for the word being typed. Known
+// users are suggested at once; the server is asked for more in the
+// background. Channels all come from the loaded channel list.
+func (m *model) refreshCompletion() tea.Cmd {
+	m.mentionSuggestions = nil
+	if !m.mainFocused() {
+		return nil
+	}
+	_, trigger, partial, ok := completionToken(m.input, m.cursorPos)
+	if !ok {
+		return nil
+	}
+	if trigger != '@' {
+		m.mentionSuggestions = m.channelSuggestions(partial)
+		return nil
+	}
+	m.mentionSuggestions = m.userSuggestions(partial)
+	if partial == "" || m.platform == nil {
+		return nil
+	}
+	return searchUsers(m.platform, partial)
+}
+
+// userSuggestions returns "@username" completions for partial from the
+// user cache, best match first
+func (m *model) userSuggestions(partial string) []string {
+	var names []string
+	for _, u := range m.users {
+		if u != nil && u.Username != "" && u.Username != m.myUsername {
+			names = append(names, u.Username)
+		}
+	}
+	sort.Strings(names) // map order is random; keep ties stable
+	var suggestions []string
+	for _, i := range fuzzyRank(partial, names) {
+		suggestions = append(suggestions, "@"+names[i])
+		if len(suggestions) == maxSuggestions {
+			break
+		}
+	}
+	return suggestions
+}
+
+// channelSuggestions returns "~name" completions for partial, matching
+// either the display name or the name. The name is what gets inserted, as
+// that is what Mattermost links.
+func (m *model) channelSuggestions(partial string) []string {
+	var names, handles []string
+	for _, ch := range m.channels {
+		if isDM(ch) {
+			continue
+		}
+		names = append(names, ch.DisplayName+" "+ch.Name)
+		handles = append(handles, ch.Name)
+	}
+	var suggestions []string
+	for _, i := range fuzzyRank(partial, names) {
+		suggestions = append(suggestions, "~"+handles[i])
+		if len(suggestions) == maxSuggestions {
+			break
+		}
+	}
+	return suggestions
+}
+
+// searchUsers asks the server for users matching query
+func searchUsers(platform platformAPI, query string) tea.Cmd {
+	return func() tea.Msg {
+		users, err := platform.SearchUsers(query)
+		if err != nil {
+			return errMsg(&opError{op: "search users", err: err})
+		}
+		return usersFoundMsg{query: query, users: users}
+	}
+}
+
+// addFoundUsers caches users from a search and, if the query is still the
+// word being typed, adds them to the popup
+func (m *model) addFoundUsers(msg usersFoundMsg) {
+	m.addUsers(msg.users)
+	if _, trigger, partial, ok := completionToken(m.input, m.cursorPos); ok && trigger == '@' && partial == msg.query {
+		m.mentionSuggestions = m.userSuggestions(partial)
+	}
+}
+
+// handleCompletionKeys handles tab and esc while the popup is shown
+func (m model) handleCompletionKeys(key string) (tea.Model, tea.Cmd, bool) {
+	if !m.mainFocused() || len(m.mentionSuggestions) == 0 {
+		return m, nil, false
+	}
+	switch {
+	case keys.action(ctxInput, key) == actComplete:
+		m.complete(m.mentionSuggestions[0])
+		return m, nil, true
+	case keys.action(ctxGeneral, key) == actCancel:
+		m.mentionSuggestions = nil
+		return m, nil, true
+	}
+	return m, nil, false
+}
+
+// complete replaces the word before the cursor with completion and a space
+func (m *model) complete(completion string) {
+	runes := []rune(m.input)
+	start, _, _, ok := completionToken(m.input, m.cursorPos)
+	if !ok {
+		return
+	}
+	rest := string(runes[min(m.cursorPos, len(runes)):])
+	m.input = string(runes[:start]) + completion + " " + rest
+	m.cursorPos = start + len([]rune(completion)) + 1
+	m.mentionSuggestions = nil
+}
+
+// renderSuggestions renders the completion popup, the top (tab) match
+// highlighted, or "" when there is nothing to suggest
+func (m model) renderSuggestions(mainWidth int) string {
+	if len(m.mentionSuggestions) == 0 {
+		return ""
+	}
+	width := 0
+	for _, s := range m.mentionSuggestions {
+		width = max(width, lipgloss.Width(s)+2)
+	}
+	width = min(width, mainWidth)
+	lines := make([]string, len(m.mentionSuggestions))
+	for i, s := range m.mentionSuggestions {
+		text := fitWidth(" "+s, width)
+		text += strings.Repeat(" ", width-lipgloss.Width(text))
+		st := style.status
+		if i == 0 {
+			st = style.highlighted
+		}
+		lines[i] = st.Render(text)
+	}
+	return strings.Join(lines, "\n")
+}
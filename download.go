@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	comm "libcommunicator"
+)
+
+// maxNameTries bounds the "name (n).ext" suffixes tried before giving up
+const maxNameTries = 1000
+
+// savedMsg reports an attachment written to path
+type savedMsg struct {
+	path string
+}
+
+// defaultDownloadDir returns ~/Downloads, or the working directory if
+// there is no home
+func defaultDownloadDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "."
+	}
+	return filepath.Join(home, "Downloads")
+}
+
+// expandHome turns a leading "~/" into the home directory, as the shell
+// would for a path given in the config file
+func expandHome(path string) string {
+	if !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[2:])
+}
+
+// createUnique creates a new file for name in dir, adding " (1)", " (2)"...
+// before the extension if the name is taken. It never overwrites.
+func createUnique(dir, name string) (*os.File, error) {
+	// The name comes from the server: keep only its last element
+	name = filepath.Base(filepath.Clean("/" + name))
+	if name == "/" || name == "." {
+		name = "attachment"
+	}
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	for n := 0; n < maxNameTries; n++ {
+		candidate := name
+		if n > 0 {
+			candidate = fmt.Sprintf("%s (%d)%s", stem, n, ext)
+		}
+		f, err := os.OpenFile(filepath.Join(dir, candidate), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		return f, err
+	}
+	return nil, fmt.Errorf("%s: too many files named like it", filepath.Join(dir, name))
+}
+
+// writeUnique writes data to a new file for name in dir, see
+// createUnique, and returns its path
+func writeUnique(dir, name string, data []byte) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	f, err := createUnique(dir, name)
+	if err != nil {
+		return "", err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name()) // no half-written files
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// downloadFile saves an attachment into dir without blocking the UI
+func downloadFile(platform platformAPI, dir string, file fileInfo) tea.Cmd {
+	return func() tea.Msg {
+		data, err := platform.DownloadFile(file.id)
+		if err == nil {
+			var path string
+			if path, err = writeUnique(dir, file.name, data); err == nil {
+				return savedMsg{path: path}
+			}
+		}
+		return errMsg(&opError{op: "download " + file.name, err: err})
+	}
+}
+
+// saveAttachment downloads an attachment of msg. Repeated presses on the
+// same message cycle through its attachments, like the links.
+func (m *model) saveAttachment(msg comm.Message) tea.Cmd {
+	files := attachments(msg)
+	if len(files) == 0 {
+		m.notice = "no attachments in message"
+		return nil
+	}
+	if msg.ID == m.fileMsgID {
+		m.fileIndex = (m.fileIndex + 1) % len(files)
+	} else {
+		m.fileMsgID = msg.ID
+		m.fileIndex = 0
+	}
+	file := files[m.fileIndex]
+	m.notice = fmt.Sprintf("downloading %s (%d/%d)", file.name, m.fileIndex+1, len(files))
+	return downloadFile(m.platform, m.downloadDir, file)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	comm "libcommunicator"
+)
+
+// emojiShortcodes maps Mattermost emoji names to the glyph shown in the
+// terminal. Names not listed are shown as :name:.
+var emojiShortcodes = map[string]string{
+	"+1":                    "👍",
+	"thumbsup":              "👍",
+	"-1":                    "👎",
+	"thumbsdown":            "👎",
+	"tada":                  "🎉",
+	"heart":                 "❤️",
+	"smile":                 "😄",
+	"slightly_smiling_face": "🙂",
+	"laughing":              "😆",
+	"joy":                   "😂",
+	"wink":                  "😉",
+	"cry":                   "😢",
+	"thinking":              "🤔",
+	"thinking_face":         "🤔",
+	"eyes":                  "👀",
+	"rocket":                "🚀",
+	"fire":                  "🔥",
+	"pray":                  "🙏",
+	"clap":                  "👏",
+	"wave":                  "👋",
+	"ok_hand":               "👌",
+	"muscle":                "💪",
+	"100":                   "💯",
+	"white_check_mark":      "✅",
+	"heavy_check_mark":      "✔️",
+	"x":                     "❌",
+	"warning":               "⚠️",
+	"star":                  "⭐",
+	"coffee":                "☕",
+	"bug":                   "🐛",
+}
+
+// emojiGlyph returns the glyph for an emoji name, or :name: if unknown
+func emojiGlyph(name string) string {
+	if glyph, ok := emojiShortcodes[name]; ok {
+		return glyph
+	}
+	return ":" + name + ":"
+}
+
+// reaction is one emoji with how many people used it on a message
+type reaction struct {
+	emoji string
+	count int
+}
+
+// reactions returns the reactions on msg grouped by emoji, in order of
+// first use. Mattermost keeps them in the post metadata, either at the top
+// level or nested under "metadata".
+func reactions(msg comm.Message) []reaction {
+	list, _ := postMetadata(msg, "reactions").([]interface{})
+
+	var result []reaction
+	index := make(map[string]int)
+	for _, item := range list {
+		r, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := r["emoji_name"].(string)
+		if name == "" {
+			continue
+		}
+		if i, ok := index[name]; ok {
+			result[i].count++
+			continue
+		}
+		index[name] = len(result)
+		result = append(result, reaction{emoji: name, count: 1})
+	}
+	return result
+}
+
+// formatReactions renders reactions as "👍 3  🎉 1"
+func formatReactions(rs []reaction) string {
+	parts := make([]string, len(rs))
+	for i, r := range rs {
+		parts[i] = fmt.Sprintf("%s %d", emojiGlyph(r.emoji), r.count)
+	}
+	return strings.Join(parts, "  ")
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// opError records which user-visible operation failed, so the error line
+// can say "send message: ..." rather than echoing a raw server error.
+type opError struct {
+	op  string
+	err error
+}
+
+func (e *opError) Error() string { return e.op + ": " + e.err.Error() }
+func (e *opError) Unwrap() error { return e.err }
+
+// platformError marks an error as the chat server's, or the network's on
+// the way to it. Only these get a hint: a local failure such as a missing
+// browser opener says "not found" for reasons of its own.
+type platformError struct {
+	err error
+}
+
+func (e *platformError) Error() string { return e.err.Error() }
+func (e *platformError) Unwrap() error { return e.err }
+
+// statusPattern finds the HTTP status in a platform error, written as
+// "status 404", "status code: 401", "HTTP 403", "error 429" or "(404 Not
+// Found)", or leading it as in "401 Unauthorized". Digits inside an ID or
+// a path never match.
+var statusPattern = regexp.MustCompile(`(?i)(?:\bstatus(?:\s+code)?|\bhttp(?:/[\d.]+)?|\berror|^|\()[\s:=]*([1-5]\d\d)\b`)
+
+// httpStatus returns the HTTP status the server answered with in err, or
+// 0 if err does not carry one
+func httpStatus(err error) int {
+	match := statusPattern.FindStringSubmatch(err.Error())
+	if match == nil {
+		return 0
+	}
+	status, _ := strconv.Atoi(match[1])
+	return status
+}
+
+// errorHint pairs an HTTP status, or else substrings of a network error,
+// with what the user can do
+type errorHint struct {
+	status int
+	match  []string
+	hint   string
+}
+
+// Pike/Cox: a table instead of a chain of ifs; first match wins
+var errorHints = []errorHint{
+	{401, nil, "session expired or token revoked; restart with fresh credentials or use -token-cmd"},
+	{403, nil, "you may not be a member of this channel"},
+	{404, nil, "the channel or message may have been deleted"},
+	{429, nil, "rate limited; wait a few seconds and retry"},
+	{0, []string{"timeout", "timed out", "deadline exceeded"}, "check your connection"},
+	{0, []string{"connection refused", "no such host", "network is unreachable"}, "server unreachable; check -host and your network"},
+}
+
+// explainError turns err into a short notice: the error, then for a
+// platform error a remediation hint
+func explainError(err error) string {
+	op := ""
+	var oe *opError
+	if errors.As(err, &oe) {
+		op = oe.op
+		err = oe.err
+	}
+
+	msg := err.Error()
+	if hint := errorHintFor(err); hint != "" {
+		msg += "; " + hint
+	}
+	if op != "" {
+		return op + " failed: " + msg
+	}
+	return msg
+}
+
+// errorHintFor returns the remediation hint for err, or "" if none applies
+func errorHintFor(err error) string {
+	var pe *platformError
+	if !errors.As(err, &pe) {
+		return ""
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return "check your connection"
+	}
+	status := httpStatus(err)
+	text := strings.ToLower(err.Error())
+	for _, h := range errorHints {
+		if h.status != 0 && h.status == status {
+			return h.hint
+		}
+		for _, match := range h.match {
+			if status == 0 && strings.Contains(text, match) {
+				return h.hint
+			}
+		}
+	}
+	return ""
+}
+
+// reportError records err for display in the error line and keeps the
+// raw error in the debug log.
+func (m *model) reportError(op string, err error) {
+	log.Printf("%s: %v", op, err)
+	m.showError(&opError{op: op, err: err})
+}
+
+// showError shows err in the error line above the status bar until a tick
+// after errorDuration clears it. The messages stay on screen.
+func (m *model) showError(err error) {
+	m.err = err
+	m.errUntil = time.Now().Add(errorDuration)
+}
+
+// showingError reports whether the error line is shown
+func (m model) showingError() bool {
+	return m.connected && m.err != nil && !m.errUntil.IsZero()
+}
+
+// renderError renders the error line with its remediation hint
+func (m model) renderError(mainWidth int) string {
+	line := fitWidth(" "+explainError(m.err), mainWidth)
+	return style.warning.Width(mainWidth).Render(line)
+}
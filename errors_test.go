@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHTTPStatus(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"401 Unauthorized", 401},
+		{"request failed with status 404", 404},
+		{"API error: status code: 403, forbidden", 403},
+		{"HTTP 429 Too Many Requests", 429},
+		{"HTTP/1.1 500 Internal Server Error", 500},
+		{"HTTP status client error (404 Not Found) for url", 404},
+		{"channel x401abc not loaded", 0},
+		{"open /tmp/404/file: no such file or directory", 0},
+		{"message 4041 is too long", 0},
+		{"connection refused", 0},
+	}
+	for _, tt := range tests {
+		if got := httpStatus(errors.New(tt.text)); got != tt.want {
+			t.Errorf("httpStatus(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestExplainError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{
+			&opError{op: "load messages", err: &platformError{errors.New("status 404: post not found")}},
+			"load messages failed: status 404: post not found; the channel or message may have been deleted",
+		},
+		{
+			&opError{op: "send message", err: &platformError{errors.New("dial tcp: connection refused")}},
+			"send message failed: dial tcp: connection refused; server unreachable; check -host and your network",
+		},
+		// A local failure gets no server advice
+		{
+			&opError{op: "open link", err: errors.New(`exec: "xdg-open": executable file not found in $PATH`)},
+			`open link failed: exec: "xdg-open": executable file not found in $PATH`,
+		},
+		// Nor does a platform error whose ID merely contains a status
+		{
+			&opError{op: "load message", err: &platformError{errors.New("no post abc401def")}},
+			"load message failed: no post abc401def",
+		},
+	}
+	for _, tt := range tests {
+		if got := explainError(tt.err); got != tt.want {
+			t.Errorf("explainError(%v) =\n  %q, want\n  %q", tt.err, got, tt.want)
+		}
+	}
+}
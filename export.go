@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	comm "libcommunicator"
+)
+
+// exportTimeLayout stamps each message of a text transcript
+const exportTimeLayout = "2006-01-02 15:04:05"
+
+// runExport writes a channel's whole history, oldest first, to a file:
+// "timestamp <nick> text" lines, or with -format json an array of the
+// objects tail -json prints
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	cf := addConnFlags(fs)
+	channel := fs.String("channel", "", "Channel ID to export (required)")
+	format := fs.String("format", "text", "Output format: text or json")
+	out := fs.String("o", "", "File to write (default CHANNEL.txt or .json here, never overwritten; - for stdout)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: termunicator export -channel ID [-format text|json] [-o FILE] [connection flags]\n\n")
+		fmt.Fprintf(os.Stderr, "Fetches the channel's history page by page back to its first message.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *channel == "" {
+		return errors.New("-channel is required")
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("unknown -format %q (want text or json)", *format)
+	}
+
+	platform, done, err := connectFlags(cf)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	msgs, err := fetchHistory(platform, *channel)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	path := *out
+	if path != "-" {
+		var f *os.File
+		if path == "" {
+			f, err = createUnique(".", exportName(platform, *channel)+"."+*format)
+		} else {
+			f, err = os.Create(path)
+		}
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		path = f.Name()
+		w = f
+	}
+
+	bw := bufio.NewWriter(w)
+	if err := writeTranscript(bw, msgs, nicknames(platform), *format); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	if path != "-" {
+		fmt.Fprintf(os.Stderr, "wrote %d messages to %s\n", len(msgs), path)
+	}
+	return nil
+}
+
+// fetchHistory returns every message in a channel, oldest first, paging
+// back with GetMessagesBefore until the server has no older ones
+func fetchHistory(platform *comm.Platform, channelID string) ([]comm.Message, error) {
+	page, err := platform.GetMessages(channelID, messageFetchLimit)
+	if err != nil {
+		return nil, fmt.Errorf("get messages failed: %w", err)
+	}
+	seen := make(map[string]bool)
+	var msgs []comm.Message
+	for len(page) > 0 {
+		added := 0
+		oldest := page[0]
+		for _, msg := range page {
+			if msg.CreatedAt.Before(oldest.CreatedAt) {
+				oldest = msg
+			}
+			if !seen[msg.ID] {
+				seen[msg.ID] = true
+				msgs = append(msgs, msg)
+				added++
+			}
+		}
+		if added == 0 {
+			break // the server repeats itself; stop rather than loop
+		}
+		fmt.Fprintf(os.Stderr, "\rfetched %d messages", len(msgs))
+		if page, err = platform.GetMessagesBefore(channelID, oldest.ID, messageFetchLimit); err != nil {
+			return nil, fmt.Errorf("get older messages failed: %w", err)
+		}
+	}
+	if len(msgs) > 0 {
+		fmt.Fprintln(os.Stderr)
+	}
+	sort.SliceStable(msgs, func(i, j int) bool { return msgs[i].CreatedAt.Before(msgs[j].CreatedAt) })
+	return msgs, nil
+}
+
+// exportName names the default output file after the channel, or its ID
+// if the channel is not in the team's list
+func exportName(platform *comm.Platform, channelID string) string {
+	if list, err := platform.GetChannels(); err == nil {
+		for _, ch := range list {
+			if ch.ID == channelID && ch.Name != "" {
+				return ch.Name
+			}
+		}
+	}
+	return channelID
+}
+
+// writeTranscript writes msgs to w in format. In text, the lines of a
+// multi-line message after the first are indented under its text.
+func writeTranscript(w io.Writer, msgs []comm.Message, nick func(string) string, format string) error {
+	if format == "json" {
+		lines := make([]tailLine, 0, len(msgs)) // [] rather than null when empty
+		for _, m := range msgs {
+			lines = append(lines, tailLine{m.ID, m.ChannelID, m.SenderID, nick(m.SenderID), m.Text, m.CreatedAt})
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(lines)
+	}
+	for _, m := range msgs {
+		prefix := fmt.Sprintf("%s <%s> ", m.CreatedAt.Local().Format(exportTimeLayout), nick(m.SenderID))
+		text := strings.ReplaceAll(m.Text, "\n", "\n"+strings.Repeat(" ", lipgloss.Width(prefix)))
+		if _, err := fmt.Fprintln(w, prefix+text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"sort"
+	"unicode"
+)
+
+// Fuzzy matching scores - Pike/Cox: named constants instead of magic numbers
+const (
+	fuzzyMatchScore       = 1 // every matched rune
+	fuzzyWordStartBonus   = 8 // match at start of name or after a separator
+	fuzzyConsecutiveBonus = 5 // match directly after the previous match
+	fuzzyGapPenaltyMax    = 3 // cap on the penalty for skipped runes
+)
+
+// fuzzyMatch reports whether every rune of pattern appears in s in order,
+// ignoring case, and scores how good the match is (higher is better).
+// Matches at word starts ("dp" in "deploy-prod") and runs of consecutive
+// runes score above matches scattered through a word ("dp" in "dependency").
+func fuzzyMatch(pattern, s string) (int, bool) {
+	p := []rune(pattern)
+	if len(p) == 0 {
+		return 0, true
+	}
+	text := []rune(s)
+	if len(p) > len(text) {
+		return 0, false
+	}
+	lower := make([]rune, len(text))
+	for i, r := range text {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	// best[j] is the best score for the pattern prefix matched so far
+	// with its last rune at text position j; ok[j] marks reachable positions.
+	best := make([]int, len(text))
+	ok := make([]bool, len(text))
+	for i, pr := range p {
+		pr = unicode.ToLower(pr)
+		next := make([]int, len(text))
+		nextOK := make([]bool, len(text))
+		for j := range text {
+			if lower[j] != pr {
+				continue
+			}
+			bonus := fuzzyMatchScore
+			if isWordStart(text, j) {
+				bonus += fuzzyWordStartBonus
+			}
+			if i == 0 {
+				next[j] = bonus - min(j, fuzzyGapPenaltyMax)
+				nextOK[j] = true
+				continue
+			}
+			for k := 0; k < j; k++ {
+				if !ok[k] {
+					continue
+				}
+				score := best[k] + bonus
+				if k == j-1 {
+					score += fuzzyConsecutiveBonus
+				} else {
+					score -= min(j-k-1, fuzzyGapPenaltyMax)
+				}
+				if !nextOK[j] || score > next[j] {
+					next[j] = score
+					nextOK[j] = true
+				}
+			}
+		}
+		best, ok = next, nextOK
+	}
+
+	score, found := 0, false
+	for j := range text {
+		if ok[j] && (!found || best[j] > score) {
+			score = best[j]
+			found = true
+		}
+	}
+	return score, found
+}
+
+// isWordStart reports whether text[i] begins a word: the first rune,
+// a rune after a separator, or an upper-case rune after a lower-case one.
+func isWordStart(text []rune, i int) bool {
+	if i == 0 {
+		return true
+	}
+	prev := text[i-1]
+	if !unicode.IsLetter(prev) && !unicode.IsDigit(prev) {
+		return true
+	}
+	return unicode.IsUpper(text[i]) && unicode.IsLower(prev)
+}
+
+// fuzzyRank returns the indices of candidates matching pattern, best match
+// first. Equal scores keep their original order. An empty pattern matches
+// everything in order. Shared by the sidebar filter and the @user and
+// ~channel completion.
+func fuzzyRank(pattern string, candidates []string) []int {
+	type scored struct {
+		index int
+		score int
+	}
+	var matches []scored
+	for i, c := range candidates {
+		if score, ok := fuzzyMatch(pattern, c); ok {
+			matches = append(matches, scored{index: i, score: score})
+		}
+	}
+	sort.SliceStable(matches, func(a, b int) bool {
+		return matches[a].score > matches[b].score
+	})
+	indices := make([]int, len(matches))
+	for i, match := range matches {
+		indices[i] = match.index
+	}
+	return indices
+}
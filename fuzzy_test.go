@@ -0,0 +1,57 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		ok         bool
+	}{
+		{"", "anything", true},
+		{"dp", "deploy-prod", true},
+		{"DP", "deploy-prod", true},
+		{"pd", "deploy-prod", true},
+		{"xyz", "deploy-prod", false},
+		{"deploy-prod-2", "deploy-prod", false},
+		{"ré", "réunion", true},
+	}
+	for _, tt := range tests {
+		if _, ok := fuzzyMatch(tt.pattern, tt.s); ok != tt.ok {
+			t.Errorf("fuzzyMatch(%q, %q) matched = %v, want %v", tt.pattern, tt.s, ok, tt.ok)
+		}
+	}
+}
+
+func TestFuzzyRank(t *testing.T) {
+	tests := []struct {
+		pattern    string
+		candidates []string
+		want       []string
+	}{
+		// Word starts beat runes scattered through a word
+		{"dp", []string{"dependency", "deploy-prod"}, []string{"deploy-prod", "dependency"}},
+		{"dp", []string{"dev-plans", "dumps"}, []string{"dev-plans", "dumps"}},
+		// So does a camel-case hump
+		{"gh", []string{"graph", "getHelp"}, []string{"getHelp", "graph"}},
+		// A consecutive run beats the same runes apart
+		{"gen", []string{"gaeanx", "general"}, []string{"general", "gaeanx"}},
+		// Earlier matches beat later ones
+		{"ran", []string{"off-random", "random"}, []string{"random", "off-random"}},
+		// Ties keep the original order; non-matches are dropped
+		{"a", []string{"bob", "alice", "anna"}, []string{"alice", "anna"}},
+		{"", []string{"c", "a", "b"}, []string{"c", "a", "b"}},
+		{"zz", []string{"alice"}, []string{}},
+	}
+	for _, tt := range tests {
+		got := []string{}
+		for _, i := range fuzzyRank(tt.pattern, tt.candidates) {
+			got = append(got, tt.candidates[i])
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("fuzzyRank(%q, %q) = %q, want %q", tt.pattern, tt.candidates, got, tt.want)
+		}
+	}
+}
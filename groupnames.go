@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	comm "libcommunicator"
+)
+
+// maxGroupNames is how many participants a group message is named after
+// before the rest are counted as "+N"
+const maxGroupNames = 3
+
+// groupMembersMsg carries the members of a group message channel
+type groupMembersMsg struct {
+	channelID string
+	members   []comm.User
+}
+
+// fetchGroupMembers loads the members of a group message channel to name
+// it by. It is only decoration, so failures are logged.
+func fetchGroupMembers(platform platformAPI, channelID string) tea.Cmd {
+	return func() tea.Msg {
+		members, err := platform.GetChannelMembers(channelID)
+		if err != nil {
+			log.Printf("fetchGroupMembers: %s: %v", channelID, err)
+			return nil
+		}
+		return groupMembersMsg{channelID: channelID, members: members}
+	}
+}
+
+// nameGroups gives the group messages Mattermost left without a display
+// name one made of their participants. Names already built are set on
+// the channel entries; for the others the members are fetched, once.
+func (m *model) nameGroups() tea.Cmd {
+	var cmds []tea.Cmd
+	for i, ch := range m.channels {
+		if ch.Type != comm.ChannelTypeGroupMessage || ch.DisplayName != "" {
+			continue
+		}
+		if name, ok := m.groupNames[ch.ID]; ok {
+			// "" while the members are being fetched
+			m.channels[i].DisplayName = name
+			continue
+		}
+		if m.groupNames == nil {
+			m.groupNames = make(map[string]string)
+		}
+		m.groupNames[ch.ID] = ""
+		cmds = append(cmds, fetchGroupMembers(m.platform, ch.ID))
+	}
+	m.navItemsDirty = true
+	return tea.Batch(cmds...)
+}
+
+// groupName joins the usernames of everyone in members but me, "alice,
+// bob, carol +2" beyond maxGroupNames
+func groupName(members []comm.User, myUserID string) string {
+	var names []string
+	for _, u := range members {
+		if u.ID != myUserID && u.Username != "" {
+			names = append(names, u.Username)
+		}
+	}
+	if len(names) > maxGroupNames {
+		return strings.Join(names[:maxGroupNames], ", ") + fmt.Sprintf(" +%d", len(names)-maxGroupNames)
+	}
+	return strings.Join(names, ", ")
+}
+
+// setGroupName names a group message channel after its members
+func (m *model) setGroupName(msg groupMembersMsg) {
+	m.addUsers(msg.members)
+	name := groupName(msg.members, m.myUserID)
+	if name == "" {
+		return
+	}
+	m.groupNames[msg.channelID] = name
+	for i, ch := range m.channels {
+		if ch.ID == msg.channelID && ch.DisplayName == "" {
+			m.channels[i].DisplayName = name
+			m.navItemsDirty = true
+		}
+	}
+}
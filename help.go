@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// keyHelp describes one keybinding for the help overlay and usage text.
+// Pike/Cox: a single table keeps both in sync as keys are added. Rows
+// for remappable actions show the keys bound to them; the rest name fixed
+// keys.
+type keyHelp struct {
+	context string   // "General", "Sidebar focus", "Main focus"
+	actions []action // keys shown are those bound to these, if any
+	keys    string
+	desc    string
+}
+
+var keyBindings = []keyHelp{
+	{"General", []action{actFocus}, "", "Switch focus (sidebar/main)"},
+	{"General", []action{actToggleReplies}, "", "Show/hide thread replies inline (saved; T only with a message selected)"},
+	{"General", []action{actToggleSystem}, "", "Show/hide joins, leaves and other system messages (saved)"},
+	{"General", []action{actHelp}, "", "Show this help (? in the message area only with a message selected)"},
+	{"General", []action{actReconnect}, "", "Reconnect now (after the connection drops)"},
+	{"General", []action{actCancel}, "", "Close a prompt, list or this help; stop editing; leave a thread"},
+	{"General", []action{actQuit}, "", "Quit (twice with unsent input)"},
+
+	{"Sidebar focus", []action{actSidebarUp, actSidebarDown}, "", "Select team/channel (cursor marker)"},
+	{"Sidebar focus", []action{actOpen}, "", "Switch to selected (active marker)"},
+	{"Sidebar focus", []action{actOpenNumber}, "", "Switch to the channel with that number"},
+	{"Sidebar focus", []action{actNarrow, actWiden}, "", "Narrow/widen the sidebar (saved)"},
+	{"Sidebar focus", []action{actFilter}, "", "Filter channels and DMs (Enter keeps, Esc clears)"},
+	{"Sidebar focus", []action{actCollapse}, "", "Collapse/expand the cursor's section"},
+
+	{"Main focus", []action{actScrollUp, actScrollDown}, "", "Scroll by line (auto-fetch older)"},
+	{"Main focus", []action{actPageUp, actPageDown}, "", "Scroll by page (auto-fetch older)"},
+	{"Main focus", []action{actScrollUp, actScrollDown}, "", "Recall sent messages (input empty, none selected)"},
+	{"Main focus", []action{actSend}, "", "Send message (/me, /shrug... run as commands)"},
+	{"Main focus", []action{actEditLast}, "", "Edit my last message (Enter saves, Esc cancels)"},
+	{"Main focus", nil, "/search words", "Search the team on the server (Enter opens a result)"},
+	{"Main focus", []action{actSearch}, "", "Search this channel's messages (Enter finds, Esc clears)"},
+	{"Main focus", []action{actAuthor}, "", "Show only one person's messages (Esc shows everyone's)"},
+	{"Main focus", []action{actComplete}, "", "Complete @user or ~channel (Esc hides suggestions)"},
+	{"Main focus", []action{actNewline}, "", "New line in message"},
+	{"Main focus", nil, "(any key)", "Type message"},
+
+	{"Input line", []action{actCursorLeft, actCursorRight}, "", "Move the cursor"},
+	{"Input line", []action{actLineStart}, "", "Start of input"},
+	{"Input line", []action{actLineEnd}, "", "End of input"},
+	{"Input line", []action{actWordLeft}, "", "Back a word"},
+	{"Input line", []action{actWordRight}, "", "Forward a word"},
+	{"Input line", []action{actDeleteBack}, "", "Delete character (also in prompts)"},
+	{"Input line", []action{actDeleteWord}, "", "Delete the word before the cursor"},
+	{"Input line", []action{actDeleteToStart, actDeleteToEnd}, "", "Delete to the start/end of input"},
+
+	{"Prompts and lists", []action{actAccept}, "", "Accept a prompt, open or pick the selected entry"},
+	{"Prompts and lists", []action{actListUp, actListDown}, "", "Move through the help, emoji or search results"},
+	{"Prompts and lists", []action{actListPageUp, actListPageDown}, "", "Move by page"},
+
+	{"Selected message", []action{actNextMatch, actPrevMatch}, "", "Next older/newer search match"},
+	{"Selected message", []action{actThread}, "", "Open its thread (Enter replies, Esc returns)"},
+	{"Selected message", []action{actOldest}, "", "Jump to the oldest loaded message (keys that do not type, also with nothing typed)"},
+	{"Selected message", []action{actNewest}, "", "Jump to the newest message and deselect (the same)"},
+	{"Selected message", []action{actCopy}, "", "Copy its text to the clipboard"},
+	{"Selected message", []action{actPin}, "", "Pin it above the messages (again unpins)"},
+	{"Selected message", []action{actReply}, "", "Reply in its thread; in a thread, quote it (resends one not sent)"},
+	{"Selected message", []action{actOpenLink}, "", "Open a link in the browser (repeat for the next)"},
+	{"Selected message", []action{actReact}, "", "React with an emoji (on one of yours, takes it back)"},
+	{"Selected message", []action{actPinned}, "", "List the channel's pinned messages (Enter opens one)"},
+	{"Selected message", []action{actSave}, "", "Save an attachment to the download directory (repeat for the next)"},
+}
+
+// helpLines returns the keybinding table grouped by context
+func helpLines() []string {
+	var lines []string
+	context := ""
+	for _, kb := range keyBindings {
+		if kb.context != context {
+			if context != "" {
+				lines = append(lines, "")
+			}
+			context = kb.context
+			lines = append(lines, context+":")
+		}
+		shown := kb.keys
+		if len(kb.actions) > 0 {
+			shown = keys.describe(kb.actions...)
+		}
+		lines = append(lines, fmt.Sprintf("  %-14s %s", shown, kb.desc))
+	}
+	return lines
+}
+
+// printKeys writes the keybinding table for flag.Usage
+func printKeys(w io.Writer) {
+	for _, line := range helpLines() {
+		fmt.Fprintf(w, "  %s\n", line)
+	}
+}
+
+// helpHeight returns how many help lines fit in the overlay
+func (m model) helpHeight() int {
+	// Border takes two lines, title and version one each
+	return max(m.height-5, 1)
+}
+
+// handleHelpKeys handles input while the help overlay is open.
+// Every key is consumed so nothing leaks into the input line.
+func (m model) handleHelpKeys(key string) (tea.Model, tea.Cmd, bool) {
+	if !m.showHelp {
+		return m, nil, false
+	}
+	switch keys.action(ctxGeneral, key) {
+	case actHelp, actCancel:
+		m.showHelp = false
+		return m, nil, true
+	case actQuit:
+		return m, nil, false // let the global handler quit
+	}
+	maxScroll := max(len(helpLines())-m.helpHeight(), 0)
+	switch keys.action(ctxPrompt, key) {
+	case actListUp:
+		m.helpScroll = max(m.helpScroll-1, 0)
+	case actListDown:
+		m.helpScroll = min(m.helpScroll+1, maxScroll)
+	case actListPageUp:
+		m.helpScroll = max(m.helpScroll-m.helpHeight(), 0)
+	case actListPageDown:
+		m.helpScroll = min(m.helpScroll+m.helpHeight(), maxScroll)
+	}
+	return m, nil, true
+}
+
+// renderHelp renders the help box showing the scrolled window of lines
+func (m model) renderHelp() string {
+	lines := helpLines()
+	start := min(m.helpScroll, max(len(lines)-m.helpHeight(), 0))
+	end := min(start+m.helpHeight(), len(lines))
+	title := "Keys (" + keys.describe(actCancel, actHelp) + " to close)"
+	if start > 0 || end < len(lines) {
+		title += fmt.Sprintf(" %d-%d/%d", start+1, end, len(lines))
+	}
+	body := style.current.Render(title) + "\n" + strings.Join(lines[start:end], "\n") +
+		"\n" + style.time.Render(versionString())
+	return lipgloss.NewStyle().
+		Border(lipgloss.NormalBorder()).
+		Padding(0, 1).
+		Render(body)
+}
+
+// overlay draws box centered on top of base, keeping the base visible
+// around it. Both may contain ANSI styling.
+func overlay(base, box string, width, height int) string {
+	baseLines := strings.Split(base, "\n")
+	for len(baseLines) < height {
+		baseLines = append(baseLines, "")
+	}
+	boxWidth := lipgloss.Width(box)
+	x := max((width-boxWidth)/2, 0)
+	y := max((height-lipgloss.Height(box))/2, 0)
+	return overlayAt(strings.Join(baseLines, "\n"), box, x, y)
+}
+
+// overlayAt draws box on top of base with its top-left corner at column x,
+// row y. Rows of box below the end of base are dropped.
+func overlayAt(base, box string, x, y int) string {
+	baseLines := strings.Split(base, "\n")
+	boxLines := strings.Split(box, "\n")
+	boxWidth := lipgloss.Width(box)
+
+	for i, boxLine := range boxLines {
+		row := y + i
+		if row >= len(baseLines) {
+			break
+		}
+		line := baseLines[row]
+		left := ansi.Truncate(line, x, "")
+		if w := ansi.StringWidth(left); w < x {
+			left += strings.Repeat(" ", x-w)
+		}
+		right := ansi.TruncateLeft(line, x+boxWidth, "")
+		baseLines[row] = left + boxLine + right
+	}
+	return strings.Join(baseLines, "\n")
+}
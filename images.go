@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"hash/fnv"
+	"image"
+	_ "image/gif" // decoders for image.Decode
+	_ "image/jpeg"
+	"image/png"
+	"log"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	comm "libcommunicator"
+)
+
+// Inline image previews (-images). Images are drawn with the Kitty or
+// iTerm2 graphics protocol in a box of rows reserved under the message;
+// elsewhere, Sixel terminals included, the attachment line is all there is.
+//
+// Kitty previews are placed with Unicode placeholders: the image is sent
+// under an id, and the box is ordinary text, cells of U+10EEEE colored with
+// that id. The terminal draws the image wherever those cells are, so the
+// renderer, which redraws only the lines that changed, moves and erases a
+// preview as it would any text.
+
+// graphics is the terminal's image protocol
+type graphics int
+
+const (
+	gfxNone graphics = iota
+	gfxKitty
+	gfxITerm
+)
+
+const (
+	imagePreviewRows = 6  // rows reserved for each preview
+	imagePreviewCols = 24 // at most this wide; the aspect ratio is kept
+	maxImageBytes    = 8 << 20
+	maxImagePixels   = 16 << 20
+	kittyChunkSize   = 4096 // base64 bytes per Kitty escape sequence
+)
+
+// kittyPlaceholder is the character whose cells show a Kitty image
+const kittyPlaceholder = '\U0010EEEE'
+
+// kittyRowMarks are the combining marks numbering the rows of a placeholder
+// box, the first of the protocol's row and column diacritics. Columns are
+// left out: each cell follows on from the one before it.
+var kittyRowMarks = [imagePreviewRows]rune{0x0305, 0x030D, 0x030E, 0x0310, 0x0312, 0x033D}
+
+// imageMsg carries a downloaded image, as PNG, or why it could not be
+type imageMsg struct {
+	fileID string
+	data   []byte
+	err    error
+}
+
+// detectGraphics guesses the image protocol from the environment; the
+// terminals that speak one say who they are. The terminal is not asked.
+func detectGraphics() graphics {
+	switch {
+	case os.Getenv("KITTY_WINDOW_ID") != "" || os.Getenv("TERM") == "xterm-kitty" || os.Getenv("TERM") == "xterm-ghostty":
+		return gfxKitty
+	case os.Getenv("TERM_PROGRAM") == "iTerm.app" || os.Getenv("TERM_PROGRAM") == "WezTerm":
+		return gfxITerm
+	}
+	return gfxNone
+}
+
+// isImage reports whether f is an image a preview can show
+func isImage(f fileInfo) bool {
+	switch f.mimeType {
+	case "image/png", "image/jpeg", "image/gif":
+		return true
+	}
+	return false
+}
+
+// fetchImage downloads an image attachment and converts it to PNG, the
+// one format every protocol takes
+func fetchImage(platform platformAPI, fileID string) tea.Cmd {
+	return func() tea.Msg {
+		data, err := platform.DownloadFile(fileID)
+		if err != nil {
+			return imageMsg{fileID: fileID, err: err}
+		}
+		data, err = toPNG(data)
+		return imageMsg{fileID: fileID, data: data, err: err}
+	}
+}
+
+// toPNG converts an image to PNG. Anything anyone in the channel uploads
+// ends up here, so both the file and the bitmap it decodes to are bounded:
+// a small file may claim huge dimensions. At 4 bytes a pixel the bitmap
+// stays under 64 MiB.
+func toPNG(data []byte) ([]byte, error) {
+	if len(data) > maxImageBytes {
+		return nil, fmt.Errorf("image is %s, over the %s limit", formatSize(int64(len(data))), formatSize(maxImageBytes))
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width > maxImagePixels/cfg.Height {
+		return nil, fmt.Errorf("image is %dx%d, over the %d pixel limit", cfg.Width, cfg.Height, maxImagePixels)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// fetchImages starts downloading the previews rendering asked for
+func (m *model) fetchImages() tea.Cmd {
+	if m.platform == nil {
+		return nil
+	}
+	var cmds []tea.Cmd
+	for id, started := range m.imageFetches {
+		if !started {
+			m.imageFetches[id] = true
+			cmds = append(cmds, fetchImage(m.platform, id))
+		}
+	}
+	return tea.Batch(cmds...)
+}
+
+// handleImage stores a downloaded preview. One that fails is logged and
+// left as its attachment line.
+func (m *model) handleImage(msg imageMsg) {
+	delete(m.imageFetches, msg.fileID)
+	if msg.err != nil {
+		log.Printf("image %s: %v", msg.fileID, msg.err)
+		m.imageFailed[msg.fileID] = true
+		m.msgPaneDirty = true
+		return
+	}
+	m.images[msg.fileID] = msg.data
+	m.msgPaneDirty = true
+}
+
+// previewImages returns the attachments of msg shown as previews
+func (m model) previewImages(msg comm.Message) []fileInfo {
+	if m.graphics == gfxNone {
+		return nil
+	}
+	var files []fileInfo
+	for _, f := range attachments(msg) {
+		if isImage(f) && !m.imageFailed[f.id] {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// imageRows returns the rows reserved for the previews of msg
+func (m model) imageRows(msg comm.Message) int {
+	return len(m.previewImages(msg)) * imagePreviewRows
+}
+
+// renderPreviews renders the preview boxes of msg, exactly imageRows(msg)
+// lines. Images not downloaded yet are asked for and show a note.
+func (m model) renderPreviews(msg comm.Message, mainWidth int) []string {
+	indent := strings.Repeat(" ", m.timeWidth+1)
+	cols := max(min(imagePreviewCols, mainWidth-len(indent)), 1)
+	blank := make([]string, imagePreviewRows-1)
+	var lines []string
+	for _, f := range m.previewImages(msg) {
+		data, ok := m.images[f.id]
+		switch {
+		case !ok:
+			if _, ok := m.imageFetches[f.id]; !ok {
+				m.imageFetches[f.id] = false // fetched after this frame
+			}
+			lines = append(lines, indent+style.time.Render(fitWidth("loading "+f.name+"…", cols)))
+			lines = append(lines, blank...)
+		case m.graphics == gfxKitty:
+			id := kittyImageID(f.id)
+			for i, row := range kittyPlaceholders(id, cols) {
+				if i == 0 {
+					row = kittyTransmit(id, data, cols, imagePreviewRows) + row
+				}
+				lines = append(lines, indent+row)
+			}
+		default:
+			lines = append(lines, indent+itermImage(data, cols, imagePreviewRows))
+			lines = append(lines, blank...)
+		}
+	}
+	return lines
+}
+
+// kittyImageID returns the Kitty image id of a file: 24 bits, as that is
+// what a placeholder's color can carry, and never 0, which means none
+func kittyImageID(fileID string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(fileID))
+	return max(h.Sum32()&0xffffff, 1)
+}
+
+// kittyTransmit returns the escape sequences sending a PNG as image id,
+// to be shown in a box of cols×rows placeholder cells. Large images go in
+// chunks. Sending it again under the same id replaces it.
+func kittyTransmit(id uint32, data []byte, cols, rows int) string {
+	enc := base64.StdEncoding.EncodeToString(data)
+	var b strings.Builder
+	for i := 0; i < len(enc); i += kittyChunkSize {
+		chunk := enc[i:min(i+kittyChunkSize, len(enc))]
+		more := 0
+		if i+kittyChunkSize < len(enc) {
+			more = 1
+		}
+		if i == 0 {
+			fmt.Fprintf(&b, "\x1b_Ga=T,U=1,f=100,i=%d,c=%d,r=%d,q=2,m=%d;%s\x1b\\", id, cols, rows, more, chunk)
+		} else {
+			fmt.Fprintf(&b, "\x1b_Gm=%d;%s\x1b\\", more, chunk)
+		}
+	}
+	return b.String()
+}
+
+// kittyPlaceholders returns the rows of placeholder cells, cols wide, that
+// show image id. The id is the cells' foreground color; the first cell of
+// each row carries the row's mark.
+func kittyPlaceholders(id uint32, cols int) []string {
+	color := fmt.Sprintf("\x1b[38;2;%d;%d;%dm", id>>16&0xff, id>>8&0xff, id&0xff)
+	rest := strings.Repeat(string(kittyPlaceholder), cols-1)
+	rows := make([]string, imagePreviewRows)
+	for i := range rows {
+		rows[i] = color + string(kittyPlaceholder) + string(kittyRowMarks[i]) + rest + "\x1b[39m"
+	}
+	return rows
+}
+
+// itermImage returns the escape sequence drawing a PNG in a box of
+// cols×rows cells at the cursor, leaving the cursor where it was so the
+// box's rows can be written as usual
+func itermImage(data []byte, cols, rows int) string {
+	enc := base64.StdEncoding.EncodeToString(data)
+	// iTerm2 moves the cursor past the image; save and restore it
+	return fmt.Sprintf("\x1b7\x1b]1337;File=inline=1;size=%d;width=%d;height=%d;preserveAspectRatio=1:%s\a\x1b8", len(data), cols, rows, enc)
+}
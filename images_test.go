@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+func TestToPNG(t *testing.T) {
+	var small bytes.Buffer
+	if err := png.Encode(&small, image.NewRGBA(image.Rect(0, 0, 40, 30))); err != nil {
+		t.Fatal(err)
+	}
+	// A GIF header claiming 60000x60000 pixels: a few bytes that would
+	// decode to 14 GB
+	huge := []byte("GIF89a\x60\xea\x60\xea\x00\x00\x00")
+
+	tests := []struct {
+		name string
+		data []byte
+		err  string // in the error, "" for none
+	}{
+		{"small PNG", small.Bytes(), ""},
+		{"huge dimensions", huge, "pixel limit"},
+		{"huge file", make([]byte, maxImageBytes+1), "over the"},
+		{"not an image", []byte("hello"), "unknown format"},
+	}
+	for _, tt := range tests {
+		out, err := toPNG(tt.data)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case tt.err == "":
+			if cfg, err := png.DecodeConfig(bytes.NewReader(out)); err != nil || cfg.Width != 40 || cfg.Height != 30 {
+				t.Errorf("%s: output is %dx%d (%v), want a 40x30 PNG", tt.name, cfg.Width, cfg.Height, err)
+			}
+		case err == nil || !strings.Contains(err.Error(), tt.err):
+			t.Errorf("%s: error %v, want one saying %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestKittyPreview(t *testing.T) {
+	id := kittyImageID("file1")
+	if id == 0 || id > 0xffffff {
+		t.Fatalf("image id %#x, want 24 bits and not 0", id)
+	}
+	if kittyImageID("file2") == id {
+		t.Errorf("two files share image id %#x", id)
+	}
+
+	rows := kittyPlaceholders(0x010203, 5)
+	if len(rows) != imagePreviewRows {
+		t.Fatalf("%d rows, want %d", len(rows), imagePreviewRows)
+	}
+	for i, row := range rows {
+		if w := ansi.StringWidth(row); w != 5 {
+			t.Errorf("row %d is %d wide, want 5", i, w)
+		}
+		if !strings.HasPrefix(row, "\x1b[38;2;1;2;3m") {
+			t.Errorf("row %d = %q, want it colored with the id", i, row)
+		}
+		if want := string(kittyPlaceholder) + string(kittyRowMarks[i]); !strings.Contains(row, want) {
+			t.Errorf("row %d = %q, want it to start with the mark of row %d", i, row, i)
+		}
+	}
+
+	data := make([]byte, 3*kittyChunkSize) // 4 chunks of base64
+	seq := kittyTransmit(id, data, 5, imagePreviewRows)
+	if want := fmt.Sprintf("\x1b_Ga=T,U=1,f=100,i=%d,c=5,r=%d,", id, imagePreviewRows); !strings.HasPrefix(seq, want) {
+		t.Errorf("transmit starts %q, want %q", seq[:min(len(seq), 60)], want)
+	}
+	if n := strings.Count(seq, "\x1b_G"); n != 4 {
+		t.Errorf("%d chunks, want 4", n)
+	}
+	if !strings.HasPrefix(seq[strings.LastIndex(seq, "\x1b_G"):], "\x1b_Gm=0;") {
+		t.Error("last chunk does not end the image")
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Input line editing. Positions are rune indices into the input, so
+// multi-byte characters (emoji, CJK) move and delete as one.
+
+// maxPasteLen caps a single paste, in runes, so that pasting a whole log
+// file by accident does not make a message the server will refuse
+const maxPasteLen = 16000
+
+// wordLeft returns the start of the word before pos, skipping any
+// whitespace directly before it
+func wordLeft(runes []rune, pos int) int {
+	pos = min(pos, len(runes))
+	for pos > 0 && unicode.IsSpace(runes[pos-1]) {
+		pos--
+	}
+	for pos > 0 && !unicode.IsSpace(runes[pos-1]) {
+		pos--
+	}
+	return pos
+}
+
+// wordRight returns the end of the word at or after pos
+func wordRight(runes []rune, pos int) int {
+	pos = max(pos, 0)
+	for pos < len(runes) && unicode.IsSpace(runes[pos]) {
+		pos++
+	}
+	for pos < len(runes) && !unicode.IsSpace(runes[pos]) {
+		pos++
+	}
+	return pos
+}
+
+// handlePaste inserts pasted text at the cursor in one go. Line endings
+// become the input's "\n"; the prompts take only the first line.
+func (m model) handlePaste(text string) (tea.Model, tea.Cmd) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	switch {
+	case m.showHelp:
+		return m, nil
+	case m.focus == focusSidebar:
+		if m.filtering {
+			line, _, _ := strings.Cut(text, "\n")
+			m.setSidebarFilter(m.sidebarFilter + line)
+		}
+		return m, nil
+	case m.searching:
+		line, _, _ := strings.Cut(text, "\n")
+		m.setSearchQuery(m.searchQuery + line)
+		return m, nil
+	case m.authorPrompt:
+		line, _, _ := strings.Cut(text, "\n")
+		m.authorInput += line
+		return m, nil
+	}
+
+	pasted := []rune(text)
+	if len(pasted) > maxPasteLen {
+		m.notice = fmt.Sprintf("paste cut to %d of %d characters", maxPasteLen, len(pasted))
+		pasted = pasted[:maxPasteLen]
+	}
+	runes := []rune(m.input)
+	m.input = string(runes[:m.cursorPos]) + string(pasted) + string(runes[m.cursorPos:])
+	m.cursorPos += len(pasted)
+	m.historyIndex = -1
+	return m, m.sendTyping()
+}
@@ -0,0 +1,52 @@
+package main
+
+import "testing"
+
+func TestWordLeft(t *testing.T) {
+	tests := []struct {
+		input string
+		pos   int
+		want  int
+	}{
+		{"", 0, 0},
+		{"hello", 5, 0},
+		{"hello world", 11, 6},
+		{"hello world", 6, 0},
+		{"hello world  ", 13, 6},
+		{"hi 😀😀 there", 5, 3},
+		{"hi 😀😀 there", 4, 3},
+		{"😀 🎉", 3, 2},
+		{"日本 語", 4, 3},
+		{"日本 語", 3, 0},
+		{"short", 99, 0},
+	}
+	for _, tt := range tests {
+		if got := wordLeft([]rune(tt.input), tt.pos); got != tt.want {
+			t.Errorf("wordLeft(%q, %d) = %d, want %d", tt.input, tt.pos, got, tt.want)
+		}
+	}
+}
+
+func TestWordRight(t *testing.T) {
+	tests := []struct {
+		input string
+		pos   int
+		want  int
+	}{
+		{"", 0, 0},
+		{"hello", 0, 5},
+		{"hello world", 0, 5},
+		{"hello world", 5, 11},
+		{"  hello", 0, 7},
+		{"hi 😀😀 there", 2, 5},
+		{"hi 😀😀 there", 4, 5},
+		{"😀 🎉", 1, 3},
+		{"日本 語", 0, 2},
+		{"short", -1, 5},
+	}
+	for _, tt := range tests {
+		if got := wordRight([]rune(tt.input), tt.pos); got != tt.want {
+			t.Errorf("wordRight(%q, %d) = %d, want %d", tt.input, tt.pos, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+// Package clipboard copies text to the system clipboard by piping it to the
+// platform's clipboard tool (pbcopy, clip, wl-copy, xclip or xsel).
+package clipboard
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// ErrUnavailable is returned when no clipboard tool is installed
+var ErrUnavailable = errors.New("clipboard: no clipboard tool found (install xclip, xsel or wl-clipboard)")
+
+// Commands returns the candidate clipboard commands for this system, best
+// first. It is a variable so tests can substitute their own.
+var Commands = func() [][]string {
+	switch runtime.GOOS {
+	case "darwin":
+		return [][]string{{"pbcopy"}}
+	case "windows":
+		return [][]string{{"clip"}}
+	}
+	var cmds [][]string
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		cmds = append(cmds, []string{"wl-copy"})
+	}
+	return append(cmds,
+		[]string{"xclip", "-selection", "clipboard"},
+		[]string{"xsel", "--clipboard", "--input"},
+		[]string{"termux-clipboard-set"},
+	)
+}
+
+// WriteAll copies text to the clipboard using the first available tool
+func WriteAll(text string) error {
+	for _, args := range Commands() {
+		path, err := exec.LookPath(args[0])
+		if err != nil {
+			continue
+		}
+		cmd := exec.Command(path, args[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		return cmd.Run()
+	}
+	return ErrUnavailable
+}
@@ -0,0 +1,52 @@
+package clipboard
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// useCommands makes WriteAll try cmds for the rest of the test
+func useCommands(t *testing.T, cmds ...[]string) {
+	saved := Commands
+	Commands = func() [][]string { return cmds }
+	t.Cleanup(func() { Commands = saved })
+}
+
+func TestWriteAll(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh to stand in for a clipboard tool")
+	}
+	out := filepath.Join(t.TempDir(), "clipboard")
+	t.Setenv("CLIPBOARD_OUT", out)
+	// A missing tool is skipped for the next one
+	useCommands(t, []string{"no-such-clipboard-tool"}, []string{"sh", "-c", `cat > "$CLIPBOARD_OUT"`})
+
+	text := "hello, 世界 😀\nsecond line"
+	if err := WriteAll(text); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil || string(data) != text {
+		t.Errorf("clipboard holds %q, %v; want %q", data, err, text)
+	}
+}
+
+func TestWriteAllUnavailable(t *testing.T) {
+	useCommands(t, []string{"no-such-clipboard-tool"}, []string{"no-such-clipboard-tool-either"})
+	if err := WriteAll("text"); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("WriteAll with no tools = %v, want ErrUnavailable", err)
+	}
+}
+
+func TestWriteAllToolFails(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh to stand in for a clipboard tool")
+	}
+	useCommands(t, []string{"sh", "-c", "exit 1"})
+	if err := WriteAll("text"); err == nil || errors.Is(err, ErrUnavailable) {
+		t.Errorf("WriteAll with a failing tool = %v, want its exit error", err)
+	}
+}
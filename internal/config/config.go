@@ -0,0 +1,205 @@
+// Package config loads termunicator's connection settings, color theme and
+// key bindings from a TOML file, and the connection settings from the
+// environment. Command-line flags are applied on top by main, so the
+// precedence is flags > environment > profile > file defaults, except that
+// a profile named on the command line ignores the environment.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// MattermostConfig is how to reach and log in to one Mattermost server
+type MattermostConfig struct {
+	Host         string `toml:"host"`   // may include a port or scheme
+	Scheme       string `toml:"scheme"` // "http" or "https" (default)
+	Token        string `toml:"token"`
+	TokenCommand string `toml:"token_command"` // shell command printing a fresh token
+	LoginID      string `toml:"login_id"`
+	Password     string `toml:"password"`
+	TeamID       string `toml:"team_id"`
+}
+
+// Config is the contents of config.toml. [mattermost] holds defaults for
+// every server; each [profiles.NAME] table overrides them for one server.
+// [theme] sets the UI colors and [keys] remaps keys.
+type Config struct {
+	Mattermost     MattermostConfig            `toml:"mattermost"`
+	DefaultProfile string                      `toml:"profile"` // used when none is named
+	Profiles       map[string]MattermostConfig `toml:"profiles"`
+	Theme          Theme                       `toml:"theme"`
+	Keys           map[string]Keys             `toml:"keys"`         // action name to keys
+	TimeFormat     string                      `toml:"time_format"`  // Go time layout for timestamps
+	DownloadDir    string                      `toml:"download_dir"` // where attachments are saved
+	URLWidth       *int                        `toml:"url_width"`    // URLs wider are shortened; 0 = never
+	ShowReplies    *bool                       `toml:"show_replies"` // thread replies inline, until toggled
+
+	env MattermostConfig // MATTERMOST_* variables, applied unless a profile is named
+}
+
+// settings lists each setting with the environment variable that sets it
+var settings = []struct {
+	env   string
+	field func(*MattermostConfig) *string
+}{
+	{"MATTERMOST_HOST", func(m *MattermostConfig) *string { return &m.Host }},
+	{"MATTERMOST_SCHEME", func(m *MattermostConfig) *string { return &m.Scheme }},
+	{"MATTERMOST_TOKEN", func(m *MattermostConfig) *string { return &m.Token }},
+	{"MATTERMOST_TOKEN_COMMAND", func(m *MattermostConfig) *string { return &m.TokenCommand }},
+	{"MATTERMOST_LOGIN_ID", func(m *MattermostConfig) *string { return &m.LoginID }},
+	{"MATTERMOST_PASSWORD", func(m *MattermostConfig) *string { return &m.Password }},
+	{"MATTERMOST_TEAM_ID", func(m *MattermostConfig) *string { return &m.TeamID }},
+}
+
+// DefaultPath returns $XDG_CONFIG_HOME/termunicator/config.toml, or the
+// platform's equivalent user config directory
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "termunicator", "config.toml"), nil
+}
+
+// Load reads the config file at DefaultPath, if there is one, and the
+// MATTERMOST_* environment variables. Use Profile for the settings to
+// connect with.
+func Load() (*Config, error) {
+	c := &Config{}
+	path, err := DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.LoadFile(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	c.env = fromEnv()
+	return c, nil
+}
+
+// LoadFile merges the settings in the TOML file at path into c. Settings
+// the file leaves out keep their current values.
+func (c *Config) LoadFile(path string) error {
+	var file Config
+	md, err := toml.DecodeFile(path, &file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return fmt.Errorf("config %s: %w", path, err)
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return fmt.Errorf("config %s: unknown setting %q", path, undecoded[0].String())
+	}
+	if err := file.Theme.validate(); err != nil {
+		return fmt.Errorf("config %s: %w", path, err)
+	}
+	c.Mattermost.merge(file.Mattermost)
+	c.Theme.merge(file.Theme)
+	for action, keys := range file.Keys {
+		if c.Keys == nil {
+			c.Keys = make(map[string]Keys)
+		}
+		c.Keys[action] = keys
+	}
+	if file.TimeFormat != "" {
+		c.TimeFormat = file.TimeFormat
+	}
+	if file.URLWidth != nil {
+		c.URLWidth = file.URLWidth
+	}
+	if file.ShowReplies != nil {
+		c.ShowReplies = file.ShowReplies
+	}
+	if file.DownloadDir != "" {
+		c.DownloadDir = file.DownloadDir
+	}
+	if file.DefaultProfile != "" {
+		c.DefaultProfile = file.DefaultProfile
+	}
+	for name, p := range file.Profiles {
+		if c.Profiles == nil {
+			c.Profiles = make(map[string]MattermostConfig)
+		}
+		c.Profiles[name] = p
+	}
+	return nil
+}
+
+// Profile returns the settings for the named profile layered over the
+// [mattermost] defaults. An empty name means the file's default profile,
+// or just the defaults if it names none, with the environment on top.
+//
+// A profile chosen by name ignores the environment: MATTERMOST_TOKEN
+// exported for one server must not be sent to another.
+func (c *Config) Profile(name string) (MattermostConfig, error) {
+	env := c.env
+	if name != "" {
+		env = MattermostConfig{}
+	} else {
+		name = c.DefaultProfile
+	}
+	m := c.Mattermost
+	if name != "" {
+		p, ok := c.Profiles[name]
+		if !ok {
+			return m, fmt.Errorf("no profile %q in config (available: %s)", name, c.profileNames())
+		}
+		m.merge(p)
+	}
+	m.merge(env)
+	return m, nil
+}
+
+// profileNames lists the defined profiles for error messages
+func (c *Config) profileNames() string {
+	if len(c.Profiles) == 0 {
+		return "none"
+	}
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// fromEnv returns the settings given by MATTERMOST_* variables
+func fromEnv() MattermostConfig {
+	var m MattermostConfig
+	for _, v := range settings {
+		*v.field(&m) = os.Getenv(v.env)
+	}
+	return m
+}
+
+// merge sets every field that is non-empty in other
+func (m *MattermostConfig) merge(other MattermostConfig) {
+	for _, v := range settings {
+		if value := *v.field(&other); value != "" {
+			*v.field(m) = value
+		}
+	}
+}
+
+// Validate checks there is enough to connect: a host, and either a token,
+// a command printing one, or a login ID with a password
+func (m MattermostConfig) Validate() error {
+	if m.Host == "" {
+		return errors.New("host is required (-host, MATTERMOST_HOST or host in config.toml)")
+	}
+	if m.Scheme != "" && m.Scheme != "http" && m.Scheme != "https" {
+		return fmt.Errorf("scheme must be http or https, not %q", m.Scheme)
+	}
+	if m.Token == "" && m.TokenCommand == "" && (m.LoginID == "" || m.Password == "") {
+		return errors.New("authentication required: a token, a token command, or a login ID and password")
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// load writes file as config.toml in a fresh config directory, sets the
+// environment variables in env, and loads both
+func load(t *testing.T, file string, env map[string]string) *Config {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	for _, v := range settings {
+		t.Setenv(v.env, env[v.env])
+	}
+	path, err := DefaultPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(file), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+const twoProfiles = `
+profile = "work"
+
+[profiles.work]
+host = "chat.work.example.com"
+token = "work-token"
+
+[profiles.personal]
+host = "chat.example.org"
+login_id = "me"
+password = "secret"
+`
+
+func TestProfileIgnoresEnv(t *testing.T) {
+	c := load(t, twoProfiles, map[string]string{"MATTERMOST_TOKEN": "exported-token"})
+
+	// The file's default profile takes the environment...
+	m, err := c.Profile("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Host != "chat.work.example.com" || m.Token != "exported-token" {
+		t.Errorf("default profile: host %q, token %q; want the work host with the exported token", m.Host, m.Token)
+	}
+
+	// ...one named on the command line does not
+	m, err = c.Profile("personal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Host != "chat.example.org" || m.Token != "" || m.LoginID != "me" {
+		t.Errorf("-profile personal: host %q, token %q, login %q; want the personal host and login only", m.Host, m.Token, m.LoginID)
+	}
+}
+
+func TestPrecedence(t *testing.T) {
+	const file = `
+[mattermost]
+host = "default.example.com"
+token = "default-token"
+team_id = "default-team"
+
+[profiles.work]
+host = "work.example.com"
+token = "work-token"
+`
+	tests := []struct {
+		name    string
+		profile string // "" for the defaults
+		env     map[string]string
+		want    MattermostConfig
+	}{
+		{"defaults", "", nil,
+			MattermostConfig{Host: "default.example.com", Token: "default-token", TeamID: "default-team"}},
+		{"environment over defaults", "", map[string]string{"MATTERMOST_TOKEN": "env-token", "MATTERMOST_SCHEME": "http"},
+			MattermostConfig{Host: "default.example.com", Scheme: "http", Token: "env-token", TeamID: "default-team"}},
+		{"profile over defaults", "work", nil,
+			MattermostConfig{Host: "work.example.com", Token: "work-token", TeamID: "default-team"}},
+		{"named profile ignores environment", "work", map[string]string{"MATTERMOST_TOKEN": "env-token"},
+			MattermostConfig{Host: "work.example.com", Token: "work-token", TeamID: "default-team"}},
+	}
+	for _, tt := range tests {
+		c := load(t, file, tt.env)
+		got, err := c.Profile(tt.profile)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	// The file's default profile is layered like the defaults, under the
+	// environment
+	c := load(t, "profile = \"work\"\n"+file, map[string]string{"MATTERMOST_TEAM_ID": "env-team"})
+	got, err := c.Profile("")
+	if want := (MattermostConfig{Host: "work.example.com", Token: "work-token", TeamID: "env-team"}); err != nil || got != want {
+		t.Errorf("default profile: got %+v, %v; want %+v", got, err, want)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		err  string // in the error, "" for none
+	}{
+		{"empty", "", ""},
+		{"everything", `
+profile = "work"
+time_format = "3:04 PM"
+url_width = 60
+show_replies = true
+download_dir = "~/chat"
+
+[mattermost]
+scheme = "https"
+
+[theme]
+nick = { fg = "2" }
+nicks = ["1", "#5f00af"]
+background = "dark"
+
+[keys]
+quit = "ctrl+q"
+sidebar_down = ["down", "j"]
+
+[profiles.work]
+host = "chat.work.example.com"
+token_command = "pass show work"
+`, ""},
+		{"unknown setting", `hots = "chat.example.com"`, `unknown setting "hots"`},
+		{"unknown mattermost setting", "[mattermost]\ntokn = \"x\"", `unknown setting "mattermost.tokn"`},
+		{"unknown profile setting", "[profiles.work]\npasword = \"x\"", `unknown setting "profiles.work.pasword"`},
+		{"unknown theme role", "[theme]\nborder = { fg = \"1\" }", `unknown setting "theme.border`},
+		{"bad key list", "[keys]\nquit = [1]", "not a string"},
+		{"bad color", "[theme]\nnick = { fg = \"purple\" }", `theme.nick: bad color "purple"`},
+		{"not TOML", "host = ", "config "},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "config.toml")
+		if err := os.WriteFile(path, []byte(tt.file), 0600); err != nil {
+			t.Fatal(err)
+		}
+		var c Config
+		err := c.LoadFile(path)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%s: error %v, want one saying %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestLoadFileKeys(t *testing.T) {
+	c := load(t, "[keys]\nquit = \"ctrl+q\"\nsidebar_down = [\"down\", \"j\"]\n", nil)
+	if got := c.Keys["quit"]; len(got) != 1 || got[0] != "ctrl+q" {
+		t.Errorf("quit = %q, want [ctrl+q]", got)
+	}
+	if got := c.Keys["sidebar_down"]; len(got) != 2 || got[1] != "j" {
+		t.Errorf("sidebar_down = %q, want [down j]", got)
+	}
+}
+
+func TestMissingProfile(t *testing.T) {
+	c := load(t, twoProfiles, nil)
+	_, err := c.Profile("home")
+	if err == nil || !strings.Contains(err.Error(), `no profile "home"`) || !strings.Contains(err.Error(), "available: personal, work") {
+		t.Errorf("missing profile: error %v, want one naming it and listing personal, work", err)
+	}
+
+	c = load(t, "", nil)
+	if _, err := c.Profile("home"); err == nil || !strings.Contains(err.Error(), "available: none") {
+		t.Errorf("no profiles: error %v, want one saying none are available", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		m    MattermostConfig
+		err  string // in the error, "" for none
+	}{
+		{"token", MattermostConfig{Host: "h", Token: "t"}, ""},
+		{"token command", MattermostConfig{Host: "h", TokenCommand: "pass show mm"}, ""},
+		{"login and password", MattermostConfig{Host: "h", LoginID: "me", Password: "p"}, ""},
+		{"http", MattermostConfig{Host: "h", Scheme: "http", Token: "t"}, ""},
+		{"no host", MattermostConfig{Token: "t"}, "host is required"},
+		{"login without password", MattermostConfig{Host: "h", LoginID: "me"}, "authentication required"},
+		{"password without login", MattermostConfig{Host: "h", Password: "p"}, "authentication required"},
+		{"nothing", MattermostConfig{Host: "h"}, "authentication required"},
+		{"bad scheme", MattermostConfig{Host: "h", Scheme: "ftp", Token: "t"}, `not "ftp"`},
+	}
+	for _, tt := range tests {
+		err := tt.m.Validate()
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%s: error %v, want one saying %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestThemeValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		theme Theme
+		err   string // in the error, "" for none
+	}{
+		{"empty", Theme{}, ""},
+		{"ANSI and hex", Theme{Nick: Color{FG: "2", BG: "#003"}, Status: Color{BG: "#005f87"}, Nicks: []string{"0", "255", "#abcdef"}}, ""},
+		{"background", Theme{Background: "light"}, ""},
+		{"color name", Theme{Time: Color{FG: "gray"}}, `theme.time: bad color "gray"`},
+		{"out of range", Theme{Warning: Color{BG: "256"}}, `theme.warning: bad color "256"`},
+		{"short hex", Theme{Code: Color{FG: "#12345"}}, `theme.code: bad color "#12345"`},
+		{"bad nick color", Theme{Nicks: []string{"1", "-1"}}, `theme.nicks: bad color "-1"`},
+		{"bad background", Theme{Background: "grey"}, `theme.background: "grey"`},
+	}
+	for _, tt := range tests {
+		err := tt.theme.validate()
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%s: error %v, want one saying %q", tt.name, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package config
+
+import "fmt"
+
+// Keys is one action's keys in the [keys] table: a string for one key,
+// sidebar_down = "j", or a list for several, sidebar_down = ["down", "j"].
+// Which actions there are is up to the UI.
+type Keys []string
+
+// UnmarshalTOML accepts a string or a list of strings
+func (k *Keys) UnmarshalTOML(v interface{}) error {
+	switch v := v.(type) {
+	case string:
+		*k = Keys{v}
+		return nil
+	case []interface{}:
+		keys := make(Keys, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return fmt.Errorf("key %v is not a string", item)
+			}
+			keys = append(keys, s)
+		}
+		*k = keys
+		return nil
+	}
+	return fmt.Errorf("want a key or a list of keys, not %v", v)
+}
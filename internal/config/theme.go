@@ -0,0 +1,109 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// Color is a foreground and a background color, each an ANSI color number
+// ("0"-"255") or hex ("#rrggbb"), or "" to keep the built-in one. In the
+// file it is a table: nick = { fg = "2" }.
+type Color struct {
+	FG string `toml:"fg"`
+	BG string `toml:"bg"`
+}
+
+// Theme overrides the UI colors by role. Roles left unset keep the
+// built-in colors.
+type Theme struct {
+	Status      Color `toml:"status"`      // status bar
+	Nick        Color `toml:"nick"`        // your own nick
+	Time        Color `toml:"time"`        // timestamps and other dim text
+	Input       Color `toml:"input"`       // input line
+	Activity    Color `toml:"activity"`    // mentions and unread badges
+	Current     Color `toml:"current"`     // active team/channel in the sidebar
+	Selected    Color `toml:"selected"`    // sidebar cursor
+	Highlighted Color `toml:"highlighted"` // selected message
+	Code        Color `toml:"code"`        // inline and fenced code
+	Warning     Color `toml:"warning"`     // error line
+
+	// Nicks are the foreground colors other people's nicks are picked
+	// from, each by a hash of the user ID
+	Nicks []string `toml:"nicks"`
+
+	// Background is "light" or "dark" to choose the built-in colors for
+	// that background when the terminal does not say; "" detects it
+	Background string `toml:"background"`
+}
+
+var hexColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// validate checks every color set is one lipgloss understands
+func (t *Theme) validate() error {
+	for _, r := range t.roles() {
+		for _, s := range []string{r.color.FG, r.color.BG} {
+			if s != "" && !validColor(s) {
+				return fmt.Errorf("theme.%s: bad color %q (want 0-255 or #rrggbb)", r.name, s)
+			}
+		}
+	}
+	if t.Background != "" && t.Background != "light" && t.Background != "dark" {
+		return fmt.Errorf("theme.background: %q is not light or dark", t.Background)
+	}
+	for _, s := range t.Nicks {
+		if !validColor(s) {
+			return fmt.Errorf("theme.nicks: bad color %q (want 0-255 or #rrggbb)", s)
+		}
+	}
+	return nil
+}
+
+// validColor reports whether s is an ANSI color number or a hex color
+func validColor(s string) bool {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n >= 0 && n <= 255
+	}
+	return hexColor.MatchString(s)
+}
+
+// role is one Theme color and its name in the file
+type role struct {
+	name  string
+	color *Color
+}
+
+// roles lists every role of t
+func (t *Theme) roles() []role {
+	return []role{
+		{"status", &t.Status},
+		{"nick", &t.Nick},
+		{"time", &t.Time},
+		{"input", &t.Input},
+		{"activity", &t.Activity},
+		{"current", &t.Current},
+		{"selected", &t.Selected},
+		{"highlighted", &t.Highlighted},
+		{"code", &t.Code},
+		{"warning", &t.Warning},
+	}
+}
+
+// merge sets every color that is non-empty in other
+func (t *Theme) merge(other Theme) {
+	theirs := other.roles()
+	for i, r := range t.roles() {
+		if c := theirs[i].color; c.FG != "" {
+			r.color.FG = c.FG
+		}
+		if c := theirs[i].color; c.BG != "" {
+			r.color.BG = c.BG
+		}
+	}
+	if len(other.Nicks) > 0 {
+		t.Nicks = other.Nicks
+	}
+	if other.Background != "" {
+		t.Background = other.Background
+	}
+}
@@ -0,0 +1,30 @@
+// Package open opens URLs with the operating system's default handler.
+package open
+
+import (
+	"os/exec"
+	"runtime"
+)
+
+// command returns the opener invocation for url on this system
+func command(url string) *exec.Cmd {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("open", url)
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	}
+	return exec.Command("xdg-open", url)
+}
+
+// URL opens url in the default browser. It returns once the opener has
+// started; the browser itself is not waited for.
+func URL(url string) error {
+	cmd := command(url)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	// Reap the opener so it doesn't linger as a zombie
+	go cmd.Wait()
+	return nil
+}
@@ -0,0 +1,80 @@
+// Package state keeps what termunicator remembers between sessions that
+// is not a setting: where reading stopped in each channel, and the team and
+// channel to reopen. It lives in
+// state.json next to prefs.json in the user config directory.
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"termunicator/internal/atomicfile"
+)
+
+// State is the contents of state.json
+type State struct {
+	LastRead    map[string]string `json:"last_read"`              // newest message seen, by channel ID
+	LastTeam    string            `json:"last_team,omitempty"`    // team of the channel open last
+	LastChannel string            `json:"last_channel,omitempty"` // reopened on the next launch
+}
+
+// Path returns the location of the state file
+func Path() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "termunicator", "state.json"), nil
+}
+
+// Load reads the saved state. A missing file is an empty state, not an
+// error.
+func Load() (*State, error) {
+	s := &State{LastRead: make(map[string]string)}
+	path, err := Path()
+	if err != nil {
+		return s, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return s, err
+	}
+	if s.LastRead == nil {
+		s.LastRead = make(map[string]string)
+	}
+	return s, nil
+}
+
+// Save writes s atomically, so a crash never leaves a truncated file
+func (s *State) Save() error {
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, data, 0600)
+}
+
+// Copy returns a copy of s that can be saved while s keeps changing
+func (s *State) Copy() *State {
+	c := &State{
+		LastRead:    make(map[string]string, len(s.LastRead)),
+		LastTeam:    s.LastTeam,
+		LastChannel: s.LastChannel,
+	}
+	for k, v := range s.LastRead {
+		c.LastRead[k] = v
+	}
+	return c
+}
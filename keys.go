@@ -0,0 +1,322 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	conf "termunicator/internal/config"
+)
+
+// action is something a key does, named as in the [keys] table of
+// config.toml. The key handlers switch on actions, never on the keys
+// bound to them.
+type action string
+
+// keyContext is where a binding applies. General bindings apply
+// everywhere; message bindings only while a message is selected in the
+// main area, on top of the main area's. Input bindings edit the input line
+// and the prompts. Prompt bindings apply in the prompts and in the lists
+// that take the keys: the help, the emoji picker and search results.
+//
+// Some contexts shadow others rather than conflict with them: a selected
+// message's keys win over the input line's (Home jumps to the oldest
+// message), and search results' over the main area's (Up moves through
+// the results).
+type keyContext int
+
+const (
+	ctxGeneral keyContext = iota
+	ctxSidebar
+	ctxMain
+	ctxMessage
+	ctxInput
+	ctxPrompt
+)
+
+const (
+	actQuit          action = "quit"
+	actFocus         action = "focus"
+	actToggleReplies action = "toggle_replies"
+	actToggleSystem  action = "toggle_system"
+	actHelp          action = "help"
+	actReconnect     action = "reconnect"
+	actCancel        action = "cancel"
+
+	actSidebarUp   action = "sidebar_up"
+	actSidebarDown action = "sidebar_down"
+	actOpen        action = "open"
+	actFilter      action = "filter"
+	actCollapse    action = "collapse"
+	actNarrow      action = "narrow"
+	actWiden       action = "widen"
+	actOpenNumber  action = "open_number"
+
+	actSend       action = "send"
+	actNewline    action = "newline"
+	actEditLast   action = "edit_last"
+	actScrollUp   action = "scroll_up"
+	actScrollDown action = "scroll_down"
+	actPageUp     action = "page_up"
+	actPageDown   action = "page_down"
+	actSearch     action = "search"
+	actAuthor     action = "author"
+
+	actThread    action = "thread"
+	actOldest    action = "oldest"
+	actNewest    action = "newest"
+	actCopy      action = "copy"
+	actPin       action = "pin"
+	actReply     action = "reply"
+	actOpenLink  action = "open_link"
+	actSave      action = "save"
+	actPinned    action = "pinned"
+	actReact     action = "react"
+	actNextMatch action = "next_match"
+	actPrevMatch action = "prev_match"
+
+	actDeleteBack    action = "delete_back"
+	actCursorLeft    action = "cursor_left"
+	actCursorRight   action = "cursor_right"
+	actLineStart     action = "line_start"
+	actLineEnd       action = "line_end"
+	actWordLeft      action = "word_left"
+	actWordRight     action = "word_right"
+	actDeleteWord    action = "delete_word"
+	actDeleteToStart action = "delete_to_start"
+	actDeleteToEnd   action = "delete_to_end"
+	actComplete      action = "complete"
+
+	actAccept       action = "accept"
+	actListUp       action = "list_up"
+	actListDown     action = "list_down"
+	actListPageUp   action = "list_page_up"
+	actListPageDown action = "list_page_down"
+)
+
+// Pike/Cox: one table of actions and their default keys, which are
+// today's behavior. Keys are as bubbletea names them ("ctrl+b", "pgup",
+// " " for space).
+var defaultBindings = []struct {
+	ctx  keyContext
+	act  action
+	keys []string
+}{
+	{ctxGeneral, actQuit, []string{"ctrl+c"}},
+	{ctxGeneral, actFocus, []string{"ctrl+b"}},
+	{ctxGeneral, actToggleReplies, []string{"ctrl+t", "T"}},
+	{ctxGeneral, actToggleSystem, []string{"ctrl+s"}},
+	{ctxGeneral, actHelp, []string{"?", "f1"}},
+	{ctxGeneral, actReconnect, []string{"ctrl+r"}},
+	{ctxGeneral, actCancel, []string{"esc"}},
+
+	{ctxSidebar, actSidebarUp, []string{"up"}},
+	{ctxSidebar, actSidebarDown, []string{"down"}},
+	{ctxSidebar, actOpen, []string{" "}},
+	{ctxSidebar, actFilter, []string{"/"}},
+	{ctxSidebar, actCollapse, []string{"ctrl+j"}},
+	{ctxSidebar, actNarrow, []string{"<"}},
+	{ctxSidebar, actWiden, []string{">"}},
+	{ctxSidebar, actOpenNumber, []string{"1", "2", "3", "4", "5", "6", "7", "8", "9"}},
+
+	{ctxMain, actSend, []string{"enter"}},
+	{ctxMain, actNewline, []string{"ctrl+enter", "ctrl+m"}},
+	{ctxMain, actEditLast, []string{"ctrl+up", "ctrl+p"}},
+	{ctxMain, actScrollUp, []string{"up"}},
+	{ctxMain, actScrollDown, []string{"down"}},
+	{ctxMain, actPageUp, []string{"pgup"}},
+	{ctxMain, actPageDown, []string{"pgdown"}},
+	{ctxMain, actSearch, []string{"ctrl+f"}},
+	{ctxMain, actAuthor, []string{"ctrl+o"}},
+
+	{ctxMessage, actThread, []string{"t"}},
+	{ctxMessage, actOldest, []string{"home", "g"}},
+	{ctxMessage, actNewest, []string{"end", "G"}},
+	{ctxMessage, actCopy, []string{"y"}},
+	{ctxMessage, actPin, []string{"p"}},
+	{ctxMessage, actReply, []string{"r"}},
+	{ctxMessage, actOpenLink, []string{"o"}},
+	{ctxMessage, actSave, []string{"s"}},
+	{ctxMessage, actPinned, []string{"P"}},
+	{ctxMessage, actReact, []string{"+"}},
+	{ctxMessage, actNextMatch, []string{"n"}},
+	{ctxMessage, actPrevMatch, []string{"N"}},
+
+	{ctxInput, actDeleteBack, []string{"backspace", "ctrl+h"}},
+	{ctxInput, actCursorLeft, []string{"left"}},
+	{ctxInput, actCursorRight, []string{"right"}},
+	{ctxInput, actLineStart, []string{"home", "ctrl+a"}},
+	{ctxInput, actLineEnd, []string{"end", "ctrl+e"}},
+	{ctxInput, actWordLeft, []string{"alt+left", "alt+b"}},
+	{ctxInput, actWordRight, []string{"alt+right", "alt+f"}},
+	{ctxInput, actDeleteWord, []string{"ctrl+w"}},
+	{ctxInput, actDeleteToStart, []string{"ctrl+u"}},
+	{ctxInput, actDeleteToEnd, []string{"ctrl+k"}},
+	{ctxInput, actComplete, []string{"tab"}},
+
+	{ctxPrompt, actAccept, []string{"enter"}},
+	{ctxPrompt, actListUp, []string{"up"}},
+	{ctxPrompt, actListDown, []string{"down"}},
+	{ctxPrompt, actListPageUp, []string{"pgup"}},
+	{ctxPrompt, actListPageDown, []string{"pgdown"}},
+}
+
+// keymap resolves pressed keys to actions
+type keymap struct {
+	actions map[keyContext]map[string]action
+	keys    map[action][]string
+}
+
+// keys is the keymap in use: the defaults until main applies [keys]
+var keys, _ = newKeymap(nil) // the defaults do not conflict
+
+// newKeymap returns the default bindings with those in overrides, action
+// name to keys, replacing them. Unknown actions and keys bound to two
+// actions that apply at once are errors.
+func newKeymap(overrides map[string]conf.Keys) (keymap, error) {
+	km := keymap{
+		actions: make(map[keyContext]map[string]action),
+		keys:    make(map[action][]string),
+	}
+	contexts := make(map[action]keyContext)
+	for _, b := range defaultBindings {
+		contexts[b.act] = b.ctx
+		km.keys[b.act] = b.keys
+	}
+
+	var problems []string
+	for name, bound := range overrides {
+		act := action(name)
+		if _, ok := contexts[act]; !ok {
+			problems = append(problems, fmt.Sprintf("unknown action %q", name))
+			continue
+		}
+		km.keys[act] = bound
+	}
+
+	for _, b := range defaultBindings {
+		if km.actions[b.ctx] == nil {
+			km.actions[b.ctx] = make(map[string]action)
+		}
+		for _, key := range km.keys[b.act] {
+			if typesText(b.ctx) && printableKey(key) {
+				problems = append(problems, fmt.Sprintf("%s: %q would no longer type into the input", b.act, key))
+				continue
+			}
+			for _, ctx := range overlapping(b.ctx) {
+				if other, ok := km.actions[ctx][key]; ok {
+					problems = append(problems, fmt.Sprintf("%q is bound to both %s and %s", key, other, b.act))
+				}
+			}
+			km.actions[b.ctx][key] = b.act
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return km, fmt.Errorf("[keys]: %s", strings.Join(problems, "; "))
+	}
+	return km, nil
+}
+
+// typesText reports whether printable keys type in ctx, and so cannot be
+// bound there
+func typesText(ctx keyContext) bool {
+	return ctx == ctxMain || ctx == ctxInput || ctx == ctxPrompt
+}
+
+// overlapping returns the contexts whose bindings can apply at the same
+// time as ctx's, including ctx
+func overlapping(ctx keyContext) []keyContext {
+	switch ctx {
+	case ctxGeneral:
+		return []keyContext{ctxGeneral, ctxSidebar, ctxMain, ctxMessage, ctxInput, ctxPrompt}
+	case ctxMain:
+		return []keyContext{ctxGeneral, ctxMain, ctxMessage, ctxInput}
+	case ctxMessage:
+		return []keyContext{ctxGeneral, ctxMain, ctxMessage}
+	case ctxInput:
+		return []keyContext{ctxGeneral, ctxMain, ctxInput, ctxPrompt}
+	case ctxPrompt:
+		return []keyContext{ctxGeneral, ctxInput, ctxPrompt}
+	}
+	return []keyContext{ctxGeneral, ctx}
+}
+
+// action returns what key does in ctx, or "" if it is not bound there
+func (km keymap) action(ctx keyContext, key string) action {
+	return km.actions[ctx][key]
+}
+
+// index returns where key is among those bound to act, or -1. The nth
+// open_number key opens the nth channel.
+func (km keymap) index(act action, key string) int {
+	return slices.Index(km.keys[act], key)
+}
+
+// printableKey reports whether key types a character
+func printableKey(key string) bool {
+	r := []rune(key)
+	return len(r) == 1 && unicode.IsPrint(r[0])
+}
+
+// keyNames are display names that capitalizing alone gets wrong
+var keyNames = map[string]string{
+	" ":      "Space",
+	"pgup":   "PgUp",
+	"pgdown": "PgDown",
+}
+
+// displayKey returns key as the help shows it: "ctrl+b" as "Ctrl+B"
+func displayKey(key string) string {
+	if name, ok := keyNames[key]; ok {
+		return name
+	}
+	parts := strings.Split(key, "+")
+	if len(parts) == 1 {
+		if len(key) == 1 {
+			return key
+		}
+		return strings.ToUpper(key[:1]) + key[1:]
+	}
+	for i, p := range parts {
+		if name, ok := keyNames[p]; ok {
+			parts[i] = name
+		} else if p != "" {
+			parts[i] = strings.ToUpper(p[:1]) + p[1:]
+		}
+	}
+	return strings.Join(parts, "+")
+}
+
+// describe returns the keys bound to acts for the help, "Up/Down". A run
+// of keys such as 1, 2 ... 9 is shown as "1-9".
+func (km keymap) describe(acts ...action) string {
+	var names []string
+	sep := "/"
+	for _, act := range acts {
+		bound := km.keys[act]
+		for i := 0; i < len(bound); i++ {
+			key := bound[i]
+			if len(key) == 1 && !unicode.IsLetter(rune(key[0])) && !unicode.IsDigit(rune(key[0])) {
+				sep = " / " // "< / >" rather than "</>"
+			}
+			j := i
+			for j+1 < len(bound) && len(bound[j+1]) == 1 && len(bound[j]) == 1 && bound[j+1][0] == bound[j][0]+1 {
+				j++
+			}
+			if j-i >= 2 {
+				names = append(names, bound[i]+"-"+bound[j])
+				i = j
+				continue
+			}
+			names = append(names, displayKey(key))
+		}
+	}
+	if len(names) == 0 {
+		return "(unbound)"
+	}
+	return strings.Join(names, sep)
+}
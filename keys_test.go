@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	conf "termunicator/internal/config"
+)
+
+func TestNewKeymap(t *testing.T) {
+	tests := []struct {
+		name      string
+		overrides map[string]conf.Keys
+		err       string // in the error, "" for none
+	}{
+		{"defaults", nil, ""},
+		{"remap", map[string]conf.Keys{"sidebar_down": {"down", "j"}, "sidebar_up": {"up", "k"}}, ""},
+		{"unknown action", map[string]conf.Keys{"explode": {"x"}}, `unknown action "explode"`},
+		{"two actions at once", map[string]conf.Keys{"focus": {"ctrl+t"}}, `"ctrl+t" is bound to both`},
+		{"search over delete word", map[string]conf.Keys{"search": {"ctrl+w"}}, `"ctrl+w" is bound to both`},
+		{"cancel in a prompt", map[string]conf.Keys{"accept": {"esc"}}, `"esc" is bound to both`},
+		{"printable in the main area", map[string]conf.Keys{"search": {"s"}}, `search: "s" would no longer type`},
+		{"printable in the input line", map[string]conf.Keys{"line_start": {"a"}}, `line_start: "a" would no longer type`},
+		{"printable in a prompt", map[string]conf.Keys{"list_up": {"k"}}, `list_up: "k" would no longer type`},
+		{"printable for a message", map[string]conf.Keys{"copy": {"c"}}, ""},
+		{"message shadows the input line", map[string]conf.Keys{"oldest": {"ctrl+a"}}, ""},
+		{"moved default frees its key", map[string]conf.Keys{"delete_word": {"alt+backspace"}, "search": {"ctrl+w"}}, ""},
+	}
+	for _, tt := range tests {
+		_, err := newKeymap(tt.overrides)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%s: error %v, want one saying %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestKeymapResolves(t *testing.T) {
+	km, err := newKeymap(map[string]conf.Keys{"oldest": {"ctrl+home"}, "open_number": {"f5", "f6"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if act := km.action(ctxMessage, "home"); act != "" {
+		t.Errorf("home with oldest remapped = %q, want nothing", act)
+	}
+	if act := km.action(ctxInput, "home"); act != actLineStart {
+		t.Errorf("home in the input line = %q, want %s", act, actLineStart)
+	}
+	if i := km.index(actOpenNumber, "f6"); i != 1 {
+		t.Errorf("f6 opens channel %d, want 1", i)
+	}
+	if act := km.action(ctxSidebar, "1"); act != "" {
+		t.Errorf("1 with open_number remapped = %q, want nothing", act)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	tests := []struct {
+		acts []action
+		want string
+	}{
+		{[]action{actOpenNumber}, "1-9"},
+		{[]action{actSidebarUp, actSidebarDown}, "Up/Down"},
+		{[]action{actNarrow, actWiden}, "< / >"},
+		{[]action{actDeleteBack}, "Backspace/Ctrl+H"},
+	}
+	for _, tt := range tests {
+		if got := keys.describe(tt.acts...); got != tt.want {
+			t.Errorf("describe(%v) = %q, want %q", tt.acts, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"log"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	comm "libcommunicator"
+
+	"termunicator/internal/state"
+)
+
+// recordRead remembers the newest loaded message of the open channel as
+// read. It reports whether anything changed.
+func (m *model) recordRead() bool {
+	if m.readState == nil || m.current < 0 || m.current >= len(m.channels) || len(m.messages) == 0 {
+		return false
+	}
+	channelID := m.channels[m.current].ID
+	newest := m.messages[len(m.messages)-1].ID
+	if m.readState.LastRead[channelID] == newest {
+		return false
+	}
+	m.readState.LastRead[channelID] = newest
+	return true
+}
+
+// saveStateCmd persists a snapshot of s, as it is now, in the background
+func saveStateCmd(s *state.State) tea.Cmd {
+	if s == nil {
+		return nil
+	}
+	return saveInBackground("state.Save", s.Copy().Save)
+}
+
+// leaveChannel records the open channel as read before switching away
+func (m *model) leaveChannel() tea.Cmd {
+	if !m.recordRead() {
+		return nil
+	}
+	return saveStateCmd(m.readState)
+}
+
+// rememberChannel records the open channel and its team, to be reopened
+// on the next launch. It reports whether anything changed.
+func (m *model) rememberChannel() bool {
+	if m.readState == nil || m.current < 0 || m.current >= len(m.channels) ||
+		m.currentTeam < 0 || m.currentTeam >= len(m.teams) {
+		return false
+	}
+	teamID, channelID := m.teams[m.currentTeam].ID, m.channels[m.current].ID
+	if m.readState.LastTeam == teamID && m.readState.LastChannel == channelID {
+		return false
+	}
+	m.readState.LastTeam, m.readState.LastChannel = teamID, channelID
+	return true
+}
+
+// resumeTeam opens the team used last, unless -teamid names another, and
+// has the channel open last reopened once the team's channels arrive (see
+// selectResumeChannel). If the team is gone it reports false, leaving the
+// team selection.
+func (m model) resumeTeam() (tea.Model, tea.Cmd, bool) {
+	if m.readState == nil || m.readState.LastTeam == "" ||
+		(m.config.teamID != "" && m.config.teamID != m.readState.LastTeam) {
+		return m, nil, false
+	}
+	for i, team := range m.teams {
+		if team.ID == m.readState.LastTeam {
+			m.selected = i
+			m.selectedType = navTeam
+			m.resumeChannel = m.readState.LastChannel
+			next, cmd := m.openSelected()
+			return next, cmd, true
+		}
+	}
+	log.Printf("last team %s is gone; showing the team selection", m.readState.LastTeam)
+	return m, nil, false
+}
+
+// selectResumeChannel puts the sidebar cursor on the channel to reopen
+// after resumeTeam, reporting whether it is still there to open
+func (m *model) selectResumeChannel() bool {
+	id := m.resumeChannel
+	if id == "" {
+		return false
+	}
+	m.resumeChannel = ""
+	for i, ch := range m.channels {
+		if ch.ID == id {
+			m.selected = i
+			m.selectedType = navChannel
+			if isDM(ch) {
+				m.selectedType = navDM
+			}
+			return true
+		}
+	}
+	log.Printf("last channel %s is gone", id)
+	return false
+}
+
+// restoreReadPosition selects the first message newer than the one last
+// read in this channel and marks it with the "new messages" divider.
+// If everything loaded was already read, the view stays at the bottom.
+func (m *model) restoreReadPosition() {
+	m.newSince = ""
+	if m.readState == nil || m.current < 0 || m.current >= len(m.channels) {
+		return
+	}
+	lastID := m.readState.LastRead[m.channels[m.current].ID]
+	if lastID == "" {
+		return
+	}
+	last := -1
+	for i, msg := range m.messages {
+		if msg.ID == lastID {
+			last = i
+			break
+		}
+	}
+	if last < 0 || last == len(m.messages)-1 {
+		// Read too long ago to be loaded, or nothing new
+		return
+	}
+	lastAt := m.messages[last].CreatedAt
+	for i, msg := range m.getDisplayMessages() {
+		if msg.CreatedAt.After(lastAt) {
+			m.newSince = msg.ID
+			m.messageCursor = i
+			m.ensureCursorVisible()
+			return
+		}
+	}
+}
+
+// messageLines is messageLineCount plus the "new messages" divider, the
+// "beginning of #channel" marker and image previews, if msg carries them
+func (m model) messageLines(msg comm.Message) int {
+	n := messageLineCount(msg) + m.imageRows(msg)
+	if msg.ID == m.newSince {
+		n++
+	}
+	if m.startsHistory(msg) {
+		n++
+	}
+	return n
+}
+
+// newMessagesDivider renders the line above the first unread message
+func newMessagesDivider(width int) string {
+	label := " new messages "
+	side := max((width-lipgloss.Width(label))/2, 0)
+	line := strings.Repeat("─", side) + label + strings.Repeat("─", side)
+	return style.activity.Render(fitWidth(line, width))
+}
@@ -4,15 +4,23 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"hash/fnv"
 	"io"
 	"log"
 	"os"
+	"sort"
 	"strings"
 	"time"
+	"unicode"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	comm "libcommunicator"
+
+	"termunicator/internal/clipboard"
+	conf "termunicator/internal/config"
+	"termunicator/internal/open"
+	"termunicator/internal/state"
 )
 
 // Constants - Pike/Cox: named constants instead of magic numbers
@@ -21,31 +29,57 @@ const (
 	messageFetchLimit     = 50
 	messagePageJumpMin    = 5
 	messagePageJumpDiv    = 2
-	messagePrefetchBuffer = 3 // Fetch older when within this many messages of top
+	messagePrefetchBuffer = 3  // Fetch older when within this many messages of top
+	maxOlderChase         = 10 // Older pages fetched in a row looking for a root post
+	mouseWheelLines       = 3  // Messages scrolled per wheel notch
+
+	// maxLoadedMessages bounds the messages kept for the open channel, so a
+	// long session scrolling back through a busy channel does not grow
+	// without limit. The price is refetching: messages trimmed off the
+	// bottom are reloaded on scrolling back down, and trimmed old ones are
+	// fetched again like any older page.
+	maxLoadedMessages = 2000
 
 	// UI dimensions
-	defaultWidth        = 80
-	defaultHeight       = 24
-	sidebarWidth        = 20
-	sidebarWidthSmall   = 15
-	minMainWidth        = 20
-	minMessageHeight    = 3
-	maxChannelsDisplay  = 9
-	maxDMsDisplay       = 5
-	minWidthForFullSide = 50
+	defaultWidth       = 80
+	defaultHeight      = 24
+	sidebarWidthDiv    = 3 // the sidebar takes at most 1/3 of the terminal
+	minSidebarWidth    = 10
+	maxSidebarWidth    = 40
+	minMainWidth       = 20
+	minMessageHeight   = 3
+	statusHeight       = 1 // status bar between messages and input
+	topicHeight        = 1 // channel topic bar above the messages
+	errorHeight        = 1 // transient error line above the status bar
+	loadingHeight      = 1 // "loading older messages" line above the messages
+	maxChannelsDisplay = 9
+	maxDMsDisplay      = 5
 
 	// Input and formatting
-	timeWidth           = 5 // "HH:MM"
-	nickPrefixLen       = 1 // "<"
-	nickSuffixLen       = 2 // "> "
-	ellipsisLen         = 3
-	minTruncateWidth    = 3
-	userIDTruncateLen   = 8
-	printableCharMin    = 32
-	printableCharMax    = 126
+	nickPrefixLen     = 1 // "<"
+	nickSuffixLen     = 2 // "> "
+	ellipsisLen       = 3
+	minTruncateWidth  = 3
+	userIDTruncateLen = 8
+	replyIndentWidth  = 2   // inline thread replies
+	codeIndentWidth   = 2   // fenced code blocks
+	mentionGutter     = "!" // between time and nick on messages mentioning me
+	printableCharMin  = 32
+	printableCharMax  = 126
+	maxSentHistory    = 100 // sent messages recallable with up/down
+
+	defaultTimeFormat = "15:04" // message timestamps (-time-format, time_format)
+	defaultURLWidth   = 40      // URLs wider are shortened (-url-width, url_width)
 
 	// Timing
 	cursorBlinkInterval      = 500 * time.Millisecond
+	flashDuration            = time.Second     // transient input-line status
+	errorDuration            = 5 * time.Second // transient error line
+	quitConfirmWindow        = 2 * time.Second // second ctrl+c with unsent input
+	typingTimeout            = 5 * time.Second // typing indicator without further events
+	maxTypingShown           = 3               // nicks named in the typing indicator
+	typingSendInterval       = 3 * time.Second // at most one typing notification per interval
+	channelRefreshDelay      = time.Second     // coalesce bursts of channel events
 	eventStreamBufferSize    = 100
 	eventStreamDebounceDelay = 100 * time.Millisecond
 )
@@ -60,26 +94,125 @@ type styles struct {
 	current     lipgloss.Style
 	selected    lipgloss.Style
 	highlighted lipgloss.Style
+	code        lipgloss.Style
+	warning     lipgloss.Style
+	nicks       []lipgloss.Style // other people's nicks, see nickStyle
 }
 
-// irssi-style colors - simple terminal colors
-var style = styles{
+// Colors that differ between dark and light terminal backgrounds;
+// lipgloss picks the variant when rendering
+var (
+	textColor   = lipgloss.AdaptiveColor{Light: "0", Dark: "15"}   // black or white
+	dimColor    = lipgloss.AdaptiveColor{Light: "244", Dark: "8"}  // gray
+	myNickColor = lipgloss.AdaptiveColor{Light: "2", Dark: "10"}   // green
+	alertColor  = lipgloss.AdaptiveColor{Light: "130", Dark: "11"} // yellow, orange on light
+	cursorColor = lipgloss.AdaptiveColor{Light: "6", Dark: "14"}   // cyan
+	codeBG      = lipgloss.AdaptiveColor{Light: "254", Dark: "8"}  // light or dark gray
+)
+
+// irssi-style colors - simple terminal colors. [theme] in config.toml
+// overrides them (see buildStyles).
+var defaultStyles = styles{
 	status:      lipgloss.NewStyle().Foreground(lipgloss.Color("15")).Background(lipgloss.Color("4")), // white on blue
-	nick:        lipgloss.NewStyle().Foreground(lipgloss.Color("10")),                                 // green
-	time:        lipgloss.NewStyle().Foreground(lipgloss.Color("8")),                                  // gray
-	input:       lipgloss.NewStyle().Foreground(lipgloss.Color("15")),                                 // white
-	activity:    lipgloss.NewStyle().Foreground(lipgloss.Color("11")),                                 // yellow
-	current:     lipgloss.NewStyle().Foreground(lipgloss.Color("11")).Bold(true),                      // yellow bold for current
-	selected:    lipgloss.NewStyle().Foreground(lipgloss.Color("14")).Bold(true),                      // cyan bold for selected
+	nick:        lipgloss.NewStyle().Foreground(myNickColor).Bold(true),                               // green bold for my own nick
+	time:        lipgloss.NewStyle().Foreground(dimColor),                                             // gray
+	input:       lipgloss.NewStyle().Foreground(textColor),                                            // white
+	activity:    lipgloss.NewStyle().Foreground(alertColor),                                           // yellow
+	current:     lipgloss.NewStyle().Foreground(alertColor).Bold(true),                                // yellow bold for current
+	selected:    lipgloss.NewStyle().Foreground(cursorColor).Bold(true),                               // cyan bold for selected
 	highlighted: lipgloss.NewStyle().Foreground(lipgloss.Color("0")).Background(lipgloss.Color("14")), // black on cyan for highlighted message
+	code:        lipgloss.NewStyle().Foreground(textColor).Background(codeBG),                         // white on gray for code
+	warning:     lipgloss.NewStyle().Foreground(lipgloss.Color("15")).Background(lipgloss.Color("1")), // white on red for errors
+	nicks:       nickStyles([]string{"1", "3", "5", "6", "9", "11", "12", "13", "14", "130", "135", "208"}),
+}
+
+// lightNicks replaces the default nick colors on a light background,
+// where the bright ones are hard to read
+var lightNicks = []string{"1", "2", "4", "5", "6", "88", "94", "130", "166", "25", "90", "28"}
+
+// nickStyles returns a nick style for each color
+func nickStyles(colors []string) []lipgloss.Style {
+	nicks := make([]lipgloss.Style, len(colors))
+	for i, c := range colors {
+		nicks[i] = lipgloss.NewStyle().Foreground(lipgloss.Color(c))
+	}
+	return nicks
+}
+
+var style = defaultStyles
+
+// buildStyles returns the default styles with the colors set by theme
+func buildStyles(theme conf.Theme) styles {
+	s := defaultStyles
+	for _, r := range []struct {
+		style *lipgloss.Style
+		color conf.Color
+	}{
+		{&s.status, theme.Status},
+		{&s.nick, theme.Nick},
+		{&s.time, theme.Time},
+		{&s.input, theme.Input},
+		{&s.activity, theme.Activity},
+		{&s.current, theme.Current},
+		{&s.selected, theme.Selected},
+		{&s.highlighted, theme.Highlighted},
+		{&s.code, theme.Code},
+		{&s.warning, theme.Warning},
+	} {
+		if r.color.FG != "" {
+			*r.style = r.style.Foreground(lipgloss.Color(r.color.FG))
+		}
+		if r.color.BG != "" {
+			*r.style = r.style.Background(lipgloss.Color(r.color.BG))
+		}
+	}
+	switch theme.Background {
+	case "light":
+		lipgloss.SetHasDarkBackground(false)
+	case "dark":
+		lipgloss.SetHasDarkBackground(true)
+	}
+	if len(theme.Nicks) > 0 {
+		s.nicks = nickStyles(theme.Nicks)
+	} else if !lipgloss.HasDarkBackground() {
+		s.nicks = nickStyles(lightNicks)
+	}
+	return s
+}
+
+// sidebarMarkers are the sidebar prefixes for the cursor and active items.
+// Either may be several runes wide; entries are aligned to the wider one.
+type sidebarMarkers struct {
+	cursor string
+	active string
+}
+
+var marker = sidebarMarkers{cursor: "*", active: ">"}
+
+// width returns the display width reserved for markers in the sidebar
+func (sm sidebarMarkers) width() int {
+	return max(lipgloss.Width(sm.cursor), lipgloss.Width(sm.active), 1)
 }
 
 type config struct {
-	host     string
-	token    string
-	loginID  string
-	password string
-	teamID   string
+	host         string
+	token        string
+	tokenCommand string // prints a fresh token, for SSO; run again when the session expires
+	loginID      string
+	password     string
+	teamID       string
+	scheme       string // "https" unless host already has one
+	insecure     bool   // skip TLS certificate verification
+	sidebarWidth int    // 0 = use saved preference
+	bell         bool   // ring and flash on mentions in other channels
+	images       bool   // preview image attachments inline, if the terminal can
+	noConfirm    bool   // quit on the first ctrl+c even with unsent input
+	timeFormat   string // Go time layout for timestamps ("" = defaultTimeFormat)
+	urlWidth     int    // URLs wider than this are shortened (0 = never, -1 = defaultURLWidth)
+	downloadDir  string // where attachments are saved ("" = defaultDownloadDir)
+	showReplies  bool   // thread replies inline unless toggled off and saved
+	theme        conf.Theme
+	keys         map[string]conf.Keys // [keys] remappings, see newKeymap
 }
 
 type focusArea int
@@ -87,6 +220,7 @@ type focusArea int
 const (
 	focusSidebar focusArea = iota
 	focusMain
+	focusThread // main area showing a single thread
 )
 
 type navItemType int
@@ -103,23 +237,65 @@ type navItem struct {
 }
 
 type model struct {
-	platform      *comm.Platform
+	platform      platformAPI
+	closePlatform func()           // logs out and releases the platform
+	reauthStatus  <-chan reauthMsg // re-authentication reports from the platform
 	eventStream   *comm.EventStream
 	teams         []comm.Team
 	channels      []comm.Channel
 	messages      []comm.Message
 	users         map[string]*comm.User // cache users by ID
+	userFetches   map[string]bool       // users not cached: true once a fetch is under way
+	myUsername    string                // current user, for highlighting mentions
+	myUserID      string                // current user's ID
+	unread        map[string]int        // messages posted this session per unopened channel ID
+	typing        map[string]time.Time  // users typing in the current channel, by last event
+	statuses      map[string]string     // presence by user ID, for the topic bar
+	groupNames    map[string]string     // names built for unnamed group messages, by channel ID
+	edited        map[string]bool       // IDs of messages edited this session
 	currentTeam   int                   // current active team
+	loadingTeam   string                // team whose channels are being fetched ("" = none)
 	current       int                   // current active channel
 	selected      int                   // selected item index (in its array)
 	selectedType  navItemType           // type of selected item
 	focus         focusArea             // which window has focus
 	scrollOffset  int                   // scroll position in message list (0 = bottom)
 	messageCursor int                   // selected message index in display messages (-1 = none)
+	showReplies   bool                  // show thread replies inline under their root
+	hideSystem    bool                  // leave out joins, leaves and other system messages
+	threadRootID  string                // root of the open thread view ("" = channel view)
+	channelScroll int                   // channel scrollOffset saved while in a thread
+	channelCursor int                   // channel messageCursor saved while in a thread
+	showHelp      bool                  // help overlay is open
+	sidebarWidth  int                   // user-chosen sidebar width (0 = automatic)
+	sidebarFilter string                // only channels/DMs matching this are listed
+	collapsed     map[navItemType]bool  // sidebar sections shown as their header only
+	filtering     bool                  // the sidebar filter prompt has the keyboard
+	sidebarScroll int                   // first channel shown in the sidebar
+	dmScroll      int                   // first DM shown in the sidebar
+	helpScroll    int                   // first visible line of the help overlay
+	totalMessages int                   // server-side message count for current channel (-1 = unknown)
+	loadingOlder  bool                  // an older page is being fetched; no second one is started
+	spinnerFrame  int                   // frame of the loading spinner, advanced by ticks
+	notice        string                // latest error or status for the notice line
+	flash         string                // transient status shown in the input line
+	flashUntil    time.Time             // when flash disappears
+	quitUntil     time.Time             // a second ctrl+c before this quits despite unsent input
+	quitting      bool                  // shutting down; another ctrl+c quits at once
+	errUntil      time.Time             // when the error line disappears (zero = no error line)
+	urlMsgID      string                // message whose links "o" last opened
+	urlIndex      int                   // which of its links was opened
+	fileMsgID     string                // message whose attachments "s" last saved
+	fileIndex     int                   // which of its attachments was saved
+	downloadDir   string                // where attachments are saved
+	outbox        []pendingSend         // sent messages the server has not confirmed, oldest first
+	nextPendingID int                   // numbers the temporary IDs of outbox entries
 	input         string
-	cursorPos     int  // cursor position in input
-	teamSelected  bool // whether a team has been selected
-	cursorVisible bool // for blinking cursor
+	sentHistory   []string // sent messages, oldest first
+	historyIndex  int      // recalled entry counted back from the newest (-1 = not browsing)
+	cursorPos     int      // cursor position in input
+	teamSelected  bool     // whether a team has been selected
+	cursorVisible bool     // for blinking cursor
 	err           error
 	connected     bool
 	ctx           context.Context
@@ -127,43 +303,212 @@ type model struct {
 	width         int
 	height        int
 	config        config
+	// Input line state
+	mentionSuggestions []string  // completions for the word being typed, best first
+	editingMessageID   string    // own message the input will replace ("" = new message)
+	editSavedInput     string    // input to restore if the edit is cancelled
+	lastTypingSent     time.Time // when we last told the server we are typing
+
+	// Inline image previews (-images), by file ID
+	graphics     graphics
+	images       map[string][]byte // downloaded, as PNG
+	imageFetches map[string]bool   // wanted: true once a download is under way
+	imageFailed  map[string]bool   // shown as the attachment line only
+
+	// Message pinned above the pane: its ID, and a copy in case it is
+	// trimmed or scrolled out of what is loaded
+	pinnedMessageID string
+	pinned          comm.Message
+
+	// Paging back through history
+	olderBefore    string // oldest loaded message when the last older page was asked for
+	olderChase     int    // older pages in a row without a root post
+	atChannelStart bool   // no older messages will be fetched
+
+	// Event stream reconnection
+	reconnecting     bool      // event stream lost; reconnect attempts in progress
+	reconnectAttempt int       // failed attempts since the stream was lost
+	lastEvent        time.Time // when the event stream last delivered anything
+
+	// Timestamps: timeWidth is the widest timeFormat renders, so that
+	// continuation lines line up however wide a given time is
+	timeFormat string
+	timeWidth  int
+
+	urlWidth int // URLs wider than this are shortened for display (0 = never)
+
+	// Author filter (ctrl+o): only authorFilter's messages are shown
+	authorPrompt bool   // prompt for the username open
+	authorInput  string // typed into the prompt
+	authorFilter string // user ID, "" = everyone
+	authorName   string // their username, for the status bar
+
+	// Message search (ctrl+f)
+	searching    bool   // search prompt open
+	searchQuery  string // highlighted; n/N step between matches
+	searchOlder  bool   // no loaded match, so older messages are being fetched
+	searchAnchor string // oldest message searched before that fetch
+
+	// Server search results (/search QUERY)
+	results        []comm.Message // newest first
+	resultsQuery   string
+	resultCursor   int
+	showingResults bool          // results shown in place of the messages
+	resultsPinned  bool          // the results are the channel's pinned messages (P)
+	jumpTarget     *comm.Message // result to select once its channel has loaded
+
+	// Emoji picker for reacting to the selected message (+)
+	picking    bool
+	pickTarget comm.Message
+	pickFilter string // typed to narrow the choices
+	pickCursor int
+
+	// Messages pinned on the server (P), by channel ID once fetched
+	pinnedPosts map[string][]comm.Message
+
+	channelRefreshPending bool // a channel list refetch is scheduled
+	statusFlash           bool // status bar inverted until the next tick (-bell)
+	newerTrimmed          bool // newest messages trimmed; reloaded on reaching the bottom
+
+	// Read positions kept across restarts
+	readState     *state.State
+	resumeChannel string // channel open last, reopened once its team's channels arrive
+	restoreRead   bool   // select the first unread message once messages load
+	newSince      string // first unread message, shown under a divider
+
 	// Performance caches (Pike/Cox: avoid repeated allocations)
-	displayMsgsCache []comm.Message // cached filtered messages
-	displayMsgsDirty bool           // true when messages changed
-	navItemsCache    []navItem      // cached nav items
-	navItemsDirty    bool           // true when teams/channels changed
+	displayMsgsCache []comm.Message  // cached filtered messages
+	displayMsgsDirty bool            // true when messages changed
+	replyCounts      map[string]int  // loaded replies per thread root ID
+	messageIDs       map[string]bool // IDs of the loaded messages
+	navItemsCache    []navItem       // cached nav items
+	navItemsDirty    bool            // true when teams/channels changed
+	navPos           map[navItem]int // position of each item in navItemsCache
+	navChannelStart  int             // first channel in navItemsCache
+	navDMStart       int             // first DM in navItemsCache
+	navNamesWidth    int             // widest sidebar entry in navItemsCache
+	msgPane          string          // rendered message pane
+	msgPaneKey       msgPaneKey      // view state msgPane was rendered for
+	msgPaneDirty     bool            // true when msgPane must be re-rendered
+
+	// Thread root ID of each loaded message ("" = root post), so metadata
+	// is parsed once per message rather than on every filter pass
+	rootIDs map[string]string
+}
+
+// msgPaneKey is the view state the rendered message pane depends on besides
+// the messages themselves, which set msgPaneDirty when they change
+type msgPaneKey struct {
+	scrollOffset, messageCursor, width, height int
 }
 
 type messagesMsg []comm.Message
 type olderMessagesMsg []comm.Message
+type olderFailedMsg struct{ err error }
 type connectedMsg struct {
-	platform    *comm.Platform
+	platform    platformAPI
+	close       func() // logs out and releases platform
+	reauth      <-chan reauthMsg
 	eventStream *comm.EventStream
+	me          *comm.User
 	teams       []comm.Team
 	channels    []comm.Channel
 }
 type newMessageMsg comm.Message
+type channelMembersMsg []comm.User
+type userFetchedMsg struct {
+	id   string
+	user *comm.User
+	err  error
+}
+type editedMessageMsg comm.Message
+type copiedMsg struct{}
+type commandResultMsg struct {
+	channelID string
+	command   string // as typed, "/away"
+	result    string // what the server said, if anything
+	err       error
+}
+type channelStatsMsg struct {
+	channelID string
+	total     int
+}
+type refreshChannelsMsg struct{}
+type channelsMsg []comm.Channel
 type eventMsg *comm.Event
 type errMsg error
 type tickMsg time.Time
 
 func initialModel(cfg config) model {
 	ctx, cancel := context.WithCancel(context.Background())
+
+	// Saved preferences first, explicit flags on top
+	p, err := loadPrefs()
+	if err != nil {
+		log.Printf("loadPrefs: %v", err)
+	}
+	if cfg.sidebarWidth > 0 {
+		p.SidebarWidth = cfg.sidebarWidth
+	}
+	readState, err := state.Load()
+	if err != nil {
+		log.Printf("state.Load: %v", err)
+	}
+	showReplies := cfg.showReplies
+	if p.InlineReplies != nil {
+		showReplies = *p.InlineReplies
+	}
+	timeFormat := cfg.timeFormat
+	if timeFormat == "" {
+		timeFormat = defaultTimeFormat
+	}
+	urlWidth := cfg.urlWidth
+	if urlWidth < 0 {
+		urlWidth = defaultURLWidth
+	}
+	downloadDir := cfg.downloadDir
+	if downloadDir == "" {
+		downloadDir = defaultDownloadDir()
+	}
+	gfx := gfxNone
+	if cfg.images {
+		if gfx = detectGraphics(); gfx == gfxNone {
+			log.Printf("-images: no Kitty or iTerm2 graphics detected (Sixel is not supported); listing attachments instead")
+		}
+	}
+
 	return model{
 		ctx:              ctx,
 		cancel:           cancel,
 		users:            make(map[string]*comm.User),
+		userFetches:      make(map[string]bool),
+		pinnedPosts:      make(map[string][]comm.Message),
+		downloadDir:      expandHome(downloadDir),
+		graphics:         gfx,
+		images:           make(map[string][]byte),
+		imageFetches:     make(map[string]bool),
+		imageFailed:      make(map[string]bool),
+		edited:           make(map[string]bool),
 		config:           cfg,
-		focus:            focusSidebar, // Start with sidebar focused for team selection
+		focus:            focusSidebar,  // Start with sidebar focused for team selection
 		current:          -1,            // No channel selected initially
 		selected:         0,             // Start at first item
 		selectedType:     navTeam,       // Start on teams
 		messageCursor:    -1,            // No message selected initially
-		cursorVisible:    true,               // Start with cursor visible
-		width:            defaultWidth,       // Default width
-		height:           defaultHeight,      // Default height
+		historyIndex:     -1,            // Not browsing sent history
+		totalMessages:    -1,            // Unknown until channel stats arrive
+		cursorVisible:    true,          // Start with cursor visible
+		width:            defaultWidth,  // Default width
+		height:           defaultHeight, // Default height
 		displayMsgsDirty: true,          // Force initial cache build
 		navItemsDirty:    true,          // Force initial cache build
+		showReplies:      showReplies,
+		hideSystem:       p.HideSystem,
+		sidebarWidth:     p.SidebarWidth,
+		timeFormat:       timeFormat,
+		timeWidth:        timeLayoutWidth(timeFormat),
+		urlWidth:         urlWidth,
+		readState:        readState,
 	}
 }
 
@@ -179,36 +524,66 @@ func tickCmd() tea.Cmd {
 }
 
 // waitForEvent waits for the next event from the event stream
+// A stream error or closed stream is reported as streamLostMsg so the
+// model can reconnect. There is nothing to wait for while reconnecting.
 func waitForEvent(stream *comm.EventStream) tea.Cmd {
+	if stream == nil {
+		return nil
+	}
 	return func() tea.Msg {
 		select {
-		case event := <-stream.Events():
+		case event, ok := <-stream.Events():
+			if !ok {
+				return streamLostMsg{stream: stream, err: fmt.Errorf("event stream closed")}
+			}
 			if event != nil {
 				return eventMsg(event)
 			}
 		case err := <-stream.Errors():
 			if err != nil {
-				return errMsg(err)
+				return streamLostMsg{stream: stream, err: err}
 			}
 		}
 		return nil
 	}
 }
 
-func (m model) connectToMattermost() tea.Msg {
+// baseURL builds the server address from host, which may carry a port
+// ("localhost:8065") or a scheme of its own ("http://localhost:8065")
+func baseURL(scheme, host string) string {
+	host = strings.TrimRight(host, "/")
+	if strings.Contains(host, "://") {
+		return host
+	}
+	if scheme == "" {
+		scheme = "https"
+	}
+	return scheme + "://" + host
+}
+
+// connect initializes libcommunicator and logs in to the server in cfg.
+// The caller disconnects and cleans up when done.
+func connect(cfg config) (*comm.Platform, error) {
 	// Initialize library
 	if err := comm.Init(); err != nil {
-		return errMsg(fmt.Errorf("init failed: %w", err))
+		return nil, fmt.Errorf("init failed: %w", err)
 	}
 
-	host := m.config.host
-	token := m.config.token
-	loginID := m.config.loginID
-	password := m.config.password
-	teamID := m.config.teamID
+	host := cfg.host
+	token := cfg.token
+	loginID := cfg.loginID
+	password := cfg.password
 
 	if host == "" {
-		return errMsg(fmt.Errorf("-host is required"))
+		return nil, fmt.Errorf("-host is required")
+	}
+
+	// A token command stands in for a token given outright
+	if token == "" && cfg.tokenCommand != "" {
+		var err error
+		if token, err = runTokenCommand(cfg.tokenCommand); err != nil {
+			return nil, err
+		}
 	}
 
 	// Check authentication method
@@ -216,39 +591,61 @@ func (m model) connectToMattermost() tea.Msg {
 	hasPassword := loginID != "" && password != ""
 
 	if !hasToken && !hasPassword {
-		return errMsg(fmt.Errorf("authentication required.\n\nOption 1 - Token:\n  -token your_token\n\nOption 2 - Password:\n  -user your_email -pass your_password"))
+		return nil, fmt.Errorf("authentication required.\n\nOption 1 - Token:\n  -token your_token\n\nOption 2 - Password:\n  -user your_email -pass your_password")
 	}
 
-	serverURL := "https://" + host
+	serverURL := baseURL(cfg.scheme, host)
 
 	// Create platform
 	platform, err := comm.NewMattermostPlatform(serverURL)
 	if err != nil {
-		return errMsg(fmt.Errorf("create platform failed: %w", err))
-	}
-
-	// Connect with appropriate auth method
-	var config *comm.PlatformConfig
-	if hasToken {
-		config = comm.NewPlatformConfig(serverURL).WithToken(token)
-	} else {
-		config = comm.NewPlatformConfig(serverURL).WithPassword(loginID, password)
+		return nil, fmt.Errorf("create platform failed: %w", err)
 	}
 
-	if teamID != "" {
-		config = config.WithTeamID(teamID)
+	if cfg.insecure {
+		log.Printf("WARNING: -insecure: TLS certificate verification is disabled for %s", serverURL)
 	}
-
+	config := platformConfig(cfg, token)
 	if err := platform.Connect(config); err != nil {
 		// Provide more helpful error messages
 		errStr := err.Error()
-		if strings.Contains(errStr, "401") {
+		if unauthorized(err) {
 			if hasToken {
-				return errMsg(fmt.Errorf("authentication failed: Invalid token.\n\nYour token: %s...\n\nPlease check:\n1. Token is a valid Personal Access Token\n2. Token hasn't been revoked\n3. You have access to the server", token[:min(10, len(token))]))
+				return nil, fmt.Errorf("authentication failed: Invalid token.\n\nYour token: %s...\n\nPlease check:\n1. Token is a valid Personal Access Token\n2. Token hasn't been revoked\n3. You have access to the server", token[:min(10, len(token))])
 			}
-			return errMsg(fmt.Errorf("authentication failed: Invalid username/password.\n\nYour username: %s\n\nPlease check:\n1. -user should be your actual email or username (not 'YOUR_EMAIL')\n2. -pass should be your actual password (not 'YOUR_PASSWORD')\n3. Account is not locked", loginID))
+			return nil, fmt.Errorf("authentication failed: Invalid username/password.\n\nYour username: %s\n\nPlease check:\n1. -user should be your actual email or username (not 'YOUR_EMAIL')\n2. -pass should be your actual password (not 'YOUR_PASSWORD')\n3. Account is not locked", loginID)
 		}
-		return errMsg(fmt.Errorf("connect failed: %w", err))
+		if strings.Contains(errStr, "x509") || strings.Contains(errStr, "certificate") {
+			return nil, fmt.Errorf("connect failed: %w\n\nThe server's TLS certificate is not trusted. For a server with a self-signed certificate, use -insecure.", err)
+		}
+		return nil, fmt.Errorf("connect failed: %w", err)
+	}
+	return platform, nil
+}
+
+// platformConfig returns the settings to log in to the server in cfg
+// with: the token if there is one, else the login ID and password
+func platformConfig(cfg config, token string) *comm.PlatformConfig {
+	serverURL := baseURL(cfg.scheme, cfg.host)
+	var config *comm.PlatformConfig
+	if token != "" {
+		config = comm.NewPlatformConfig(serverURL).WithToken(token)
+	} else {
+		config = comm.NewPlatformConfig(serverURL).WithPassword(cfg.loginID, cfg.password)
+	}
+	if cfg.teamID != "" {
+		config = config.WithTeamID(cfg.teamID)
+	}
+	if cfg.insecure {
+		config = config.WithInsecureSkipVerify(true)
+	}
+	return config
+}
+
+func (m model) connectToMattermost() tea.Msg {
+	platform, err := connect(m.config)
+	if err != nil {
+		return errMsg(err)
 	}
 
 	// Get teams only - channels will be fetched when user selects a team
@@ -257,6 +654,12 @@ func (m model) connectToMattermost() tea.Msg {
 		return errMsg(fmt.Errorf("get teams failed: %w", err))
 	}
 
+	// Who we are, for highlighting mentions; not fatal if unavailable
+	me, err := platform.GetCurrentUser()
+	if err != nil {
+		log.Printf("GetCurrentUser: %v", err)
+	}
+
 	// Create event stream for real-time updates
 	ctx := context.Background()
 	eventStream, err := platform.NewEventStream(ctx, eventStreamBufferSize, eventStreamDebounceDelay)
@@ -264,42 +667,143 @@ func (m model) connectToMattermost() tea.Msg {
 		return errMsg(fmt.Errorf("create event stream failed: %w", err))
 	}
 
-	return connectedMsg{platform: platform, eventStream: eventStream, teams: teams, channels: nil}
+	closePlatform := func() {
+		platform.Disconnect()
+		platform.Destroy()
+	}
+	// From here on an expired session logs in again rather than failing
+	reauth := newReauthPlatform(platform, m.config)
+	return connectedMsg{platform: reauth, reauth: reauth.status, close: closePlatform, eventStream: eventStream, me: me, teams: teams, channels: nil}
 }
 
+// Update applies msg and then rebuilds any invalidated caches, so that View
+// (which works on a copy of the model) never has to rebuild them per frame.
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	next, cmd := m.update(msg)
+	nm := next.(model)
+	nm.getNavItems()
+	nm.getDisplayMessages()
+	nm.cacheMessagePane()
+	cmd = tea.Batch(cmd, nm.fetchUsers(), nm.fetchImages())
+	// Every edit of the input, however made, updates completion
+	if nm.input != m.input || nm.cursorPos != m.cursorPos {
+		cmd = tea.Batch(cmd, nm.refreshCompletion())
+	}
+	return nm, cmd
+}
+
+func (m model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if newModel, cmd, handled := m.handleReconnect(msg); handled {
+		return newModel, cmd
+	}
+	if newModel, cmd, handled := m.handleSearchResults(msg); handled {
+		return newModel, cmd
+	}
+	if newModel, cmd, handled := m.handleJump(msg); handled {
+		return newModel, cmd
+	}
+	if newModel, cmd, handled := m.handleOutbox(msg); handled {
+		return newModel, cmd
+	}
+	if newModel, cmd, handled := m.handleReauth(msg); handled {
+		return newModel, cmd
+	}
+
 	switch msg := msg.(type) {
+	case signalMsg:
+		log.Printf("received %v, quitting", msg.sig)
+		return m, m.quit()
+
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
+		// What fits changed: keep the scroll position in range and the
+		// selected message on screen
+		m.scrollOffset = m.clampScrollOffset(m.scrollOffset)
+		if m.messageCursor >= 0 {
+			m.ensureCursorVisible()
+		}
+		m.helpScroll = min(m.helpScroll, max(len(helpLines())-m.helpHeight(), 0))
+		m.msgPaneDirty = true
 		return m, nil
 
+	case tea.MouseMsg:
+		return m.handleMouse(msg)
+
 	case tea.KeyMsg:
+		// Bracketed paste arrives as one message rather than keystrokes
+		if msg.Paste {
+			return m.handlePaste(string(msg.Runes))
+		}
 		key := msg.String()
 
+		// The help overlay swallows keys while open
+		if newModel, cmd, handled := m.handleHelpKeys(key); handled {
+			return newModel, cmd
+		}
+
+		// So does the emoji picker, but for ctrl+c
+		if newModel, cmd, handled := m.handlePickerKeys(key); handled {
+			return newModel, cmd
+		}
+
 		// Try global keys first (ctrl+c, ctrl+b)
 		if newModel, cmd, handled := m.handleGlobalKeys(key); handled {
 			return newModel, cmd
 		}
 
 		// Try sidebar-specific keys
+		if newModel, cmd, handled := m.handleFilterKeys(key); handled {
+			return newModel, cmd
+		}
 		if newModel, cmd, handled := m.handleSidebarKeys(key); handled {
 			return newModel, cmd
 		}
 
+		// Server search results replace the messages while shown
+		if newModel, cmd, handled := m.handleResultKeys(key); handled {
+			return newModel, cmd
+		}
+
+		// The search prompt, and n/N between matches
+		if newModel, cmd, handled := m.handleSearchKeys(key); handled {
+			return newModel, cmd
+		}
+
+		// Tab/esc act on the completion popup while it is open
+		if newModel, cmd, handled := m.handleCompletionKeys(key); handled {
+			return newModel, cmd
+		}
+
+		// The author prompt, and esc clearing the filter
+		if newModel, cmd, handled := m.handleAuthorKeys(key); handled {
+			return newModel, cmd
+		}
+
+		// Try commands on the selected message
+		if newModel, cmd, handled := m.handleMessageKeys(key); handled {
+			return newModel, cmd
+		}
+
 		// Try main area keys
 		if newModel, cmd, handled := m.handleMainKeys(key); handled {
 			return newModel, cmd
 		}
 
 		// Try regular character input
-		if newModel, cmd, handled := m.handleInputChar(key); handled {
+		if newModel, cmd, handled := m.handleInputChar(msg); handled {
 			return newModel, cmd
 		}
 
 	case connectedMsg:
 		m.platform = msg.platform
+		m.closePlatform = msg.close
+		m.reauthStatus = msg.reauth
 		m.eventStream = msg.eventStream
+		if msg.me != nil {
+			m.myUsername = msg.me.Username
+			m.myUserID = msg.me.ID
+		}
 		m.teams = msg.teams
 		m.channels = msg.channels
 		m.connected = true
@@ -313,51 +817,72 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 			}
 		}
-		// Always show team selection screen - user must select with arrow keys
 		// Start listening for events
-		return m, waitForEvent(m.eventStream)
+		listen := tea.Batch(waitForEvent(m.eventStream), waitForReauth(m.reauthStatus))
+		// Back to the team and channel used last; without them, the
+		// team selection screen
+		if next, cmd, ok := m.resumeTeam(); ok {
+			return next, tea.Batch(listen, cmd)
+		}
+		return m, listen
 
 	case eventMsg:
 		// Handle real-time events
 		if msg != nil {
+			m.lastEvent = time.Now()
 			switch msg.Type {
 			case comm.EventMessagePosted:
-				// Try MessageID first, then extract from Data if needed
-				msgID := msg.MessageID
-				if msgID == "" && msg.Data != nil {
-					if dataMap, ok := msg.Data.(map[string]interface{}); ok {
-						if id, ok := dataMap["id"].(string); ok {
-							msgID = id
-						}
-					}
-				}
-				if msgID != "" {
+				m.countUnread(msg)
+				delete(m.typing, msg.UserID) // they sent it
+				if msgID := eventMessageID(msg); msgID != "" {
 					return m, tea.Batch(
 						waitForEvent(m.eventStream),
 						fetchMessage(m.platform, msgID),
 					)
 				}
 			case comm.EventMessageUpdated:
-				// Message was edited - could refresh if needed
-				// For now, just ignore
+				// Message was edited - refetch it and replace in place
+				if msgID := eventMessageID(msg); msgID != "" {
+					return m, tea.Batch(
+						waitForEvent(m.eventStream),
+						fetchEditedMessage(m.platform, msgID),
+					)
+				}
 			case comm.EventMessageDeleted:
-				// Message was deleted - could remove from display
-				// For now, just ignore
+				// Message was deleted - drop it from the scrollback
+				if msgID := eventMessageID(msg); msgID != "" {
+					m.removeMessage(msgID)
+				}
 			case comm.EventUserStatusChanged:
-				// User status changed - could update user cache
-				// For now, just ignore
+				// Shown in the topic bar of direct messages
+				m.setStatus(msg.UserID, eventStatus(msg))
 			case comm.EventUserTyping:
-				// User is typing - could show indicator
-				// For now, just ignore
+				if m.current >= 0 && m.current < len(m.channels) && msg.ChannelID == m.channels[m.current].ID && msg.UserID != m.myUserID {
+					if m.typing == nil {
+						m.typing = make(map[string]time.Time)
+					}
+					m.typing[msg.UserID] = time.Now()
+					m.nick(msg.UserID) // resolve now rather than while rendering
+				}
 			case comm.EventChannelCreated, comm.EventChannelUpdated, comm.EventChannelDeleted:
-				// Channel changed - could refresh channel list
-				// For now, just ignore
+				// Refetch the channel list once the burst settles
+				if m.teamSelected && !m.channelRefreshPending {
+					m.channelRefreshPending = true
+					return m, tea.Batch(
+						waitForEvent(m.eventStream),
+						tea.Tick(channelRefreshDelay, func(time.Time) tea.Msg { return refreshChannelsMsg{} }),
+					)
+				}
+			case comm.EventReactionAdded, comm.EventReactionRemoved:
+				// Update the reaction line in place
+				m.applyReaction(msg, msg.Type == comm.EventReactionAdded)
 			case comm.EventUserJoinedChannel, comm.EventUserLeftChannel:
 				// User joined/left channel
 				// For now, just ignore
 			case comm.EventConnectionStateChange:
-				// Connection state changed
-				// For now, just ignore
+				if connectionLost(msg) {
+					return m, m.streamLost(fmt.Errorf("connection state: %v", msg.Data))
+				}
 			default:
 				// Unknown event type - ignore silently
 			}
@@ -365,33 +890,68 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// Continue listening for events
 		return m, waitForEvent(m.eventStream)
 
+	case channelMembersMsg:
+		m.addUsers(msg)
+		return m, nil
+
+	case userStatusMsg:
+		m.setStatus(msg.userID, msg.status)
+		return m, nil
+
+	case imageMsg:
+		m.handleImage(msg)
+		return m, nil
+
+	case userFetchedMsg:
+		if msg.err != nil || msg.user == nil {
+			log.Printf("get user %s: %v", msg.id, msg.err)
+			return m, nil
+		}
+		delete(m.userFetches, msg.id)
+		m.users[msg.id] = msg.user
+		m.msgPaneDirty = true // show the name instead of the ID
+		return m, nil
+
 	case newMessageMsg:
+		newMsg := comm.Message(msg)
+		m.alertMention(newMsg)
+		m.reconcileSent(newMsg)
 		// Append new message to current channel
 		if m.current >= 0 && m.current < len(m.channels) {
-			newMsg := comm.Message(msg)
 			if newMsg.ChannelID == m.channels[m.current].ID {
-				// Check if message already exists (avoid duplicates)
-				exists := false
-				for _, existingMsg := range m.messages {
-					if existingMsg.ID == newMsg.ID {
-						exists = true
-						break
-					}
-				}
-				if !exists {
+				// Check if message already exists (avoid duplicates).
+				// With the newest trimmed it arrives with their reload.
+				if !m.hasMessage(newMsg.ID) && !m.newerTrimmed {
 					// If at bottom, stay at bottom to show new message
 					wasAtBottom := m.scrollOffset == 0
 					m.messages = append(m.messages, newMsg)
 					m.displayMsgsDirty = true // Invalidate cache
 					if wasAtBottom {
 						m.scrollOffset = 0
+						m.trimMessages(false)
 					} else {
 						m.scrollOffset = m.clampScrollOffset(m.scrollOffset)
 					}
+					// We are looking at it, so it is read
+					return m, tea.Batch(markChannelRead(m.platform, newMsg.ChannelID), waitForEvent(m.eventStream))
 				}
 			}
 		}
 
+	case editedMessageMsg:
+		// Replace the edited message in place; indices are unchanged so
+		// scroll position and message cursor stay put
+		edited := comm.Message(msg)
+		for i := range m.messages {
+			if m.messages[i].ID == edited.ID {
+				m.messages[i] = edited
+				m.edited[edited.ID] = true
+				m.displayMsgsDirty = true // Invalidate cache
+				m.scrollOffset = m.clampScrollOffset(m.scrollOffset)
+				break
+			}
+		}
+
 	case messagesMsg:
 		log.Printf("messagesMsg: received %d messages for channel", len(msg))
 
@@ -408,15 +968,23 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		log.Printf("messagesMsg: %d root posts, %d thread replies", displayCount, threadReplyCount)
 
 		m.messages = msg
+		m.newerTrimmed = false
+		m.searchOlder = false
+		m.olderChase = 0
+		m.atChannelStart = false
 		m.displayMsgsDirty = true // Invalidate cache
 		m.scrollOffset = 0        // Reset scroll to bottom (newest messages) when loading new channel
 		m.messageCursor = -1      // Reset cursor when messages are replaced
+		displayCount = len(m.getDisplayMessages())
+		if m.restoreRead {
+			m.restoreRead = false
+			m.restoreReadPosition()
+		}
 
-		// If no root posts in initial load, fetch older messages
+		// If nothing displayable in initial load, fetch older messages
 		if displayCount == 0 && len(msg) > 0 && m.current >= 0 && m.current < len(m.channels) {
 			log.Printf("messagesMsg: no root posts in initial load, fetching older...")
-			oldestMsg := msg[0]
-			return m, fetchOlderMessages(m.platform, m.channels[m.current].ID, oldestMsg.ID)
+			return m, m.loadOlder()
 		} else if displayCount > 0 {
 			log.Printf("messagesMsg: showing %d root posts", displayCount)
 		} else {
@@ -424,6 +992,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 	case olderMessagesMsg:
+		m.loadingOlder = false
 		// Prepend older messages to the beginning (with deduplication)
 		log.Printf("olderMessagesMsg: received %d messages from server", len(msg))
 		if len(msg) > 0 {
@@ -436,15 +1005,9 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			newMessages := make([]comm.Message, 0, len(msg))
 			duplicateCount := 0
 			for _, fetchedMsg := range msg {
-				exists := false
-				for _, existingMsg := range m.messages {
-					if existingMsg.ID == fetchedMsg.ID {
-						exists = true
-						duplicateCount++
-						break
-					}
-				}
-				if !exists {
+				if m.hasMessage(fetchedMsg.ID) {
+					duplicateCount++
+				} else {
 					newMessages = append(newMessages, fetchedMsg)
 				}
 			}
@@ -474,14 +1037,18 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 			// Add messages to storage (even if all duplicates, still track for pagination)
 			if len(newMessages) > 0 {
+				shownBefore := len(m.getDisplayMessages())
 				m.messages = append(newMessages, m.messages...)
 				m.displayMsgsDirty = true // Invalidate cache
+				// With replies inline every new message is displayed
+				displayCount = len(m.getDisplayMessages()) - shownBefore
 			}
 
 			// Decide what to do based on whether we got displayable root posts
 			if displayCount > 0 {
 				// Got root posts - show them
 				log.Printf("olderMessagesMsg: SUCCESS - showing %d root posts", displayCount)
+				m.olderChase = 0
 
 				if m.messageCursor >= 0 {
 					m.messageCursor += displayCount
@@ -499,16 +1066,26 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 				// Ensure cursor stays visible after all adjustments
 				m.ensureCursorVisible()
+				m.trimMessages(true)
 			} else {
 				// Server returned messages but no displayable root posts
 				// Only continue if we got NEW messages (not all duplicates)
-				if len(newMessages) > 0 && m.current >= 0 && m.current < len(m.channels) && len(m.messages) > 0 {
-					oldestMsg := m.messages[0]
-					log.Printf("olderMessagesMsg: no root posts found, continuing to fetch older (using oldest message ID=%s)", oldestMsg.ID)
-					return m, fetchOlderMessages(m.platform, m.channels[m.current].ID, oldestMsg.ID)
+				// A thread-heavy channel can take many pages to reach a root
+				// post; give up after maxOlderChase pages in a row, or at once
+				// if the oldest message did not move
+				m.olderChase++
+				if m.olderChase >= maxOlderChase || (len(m.messages) > 0 && m.messages[0].ID == m.olderBefore) {
+					log.Printf("olderMessagesMsg: STOP - %d pages without a root post", m.olderChase)
+					m.atChannelStart = true
+					m.msgPaneDirty = true
+				} else if len(newMessages) > 0 && m.current >= 0 && m.current < len(m.channels) && len(m.messages) > 0 {
+					log.Printf("olderMessagesMsg: no root posts found, continuing to fetch older (using oldest message ID=%s)", m.messages[0].ID)
+					return m, m.loadOlder()
 				} else {
 					if len(newMessages) == 0 {
 						log.Printf("olderMessagesMsg: STOP - all messages were duplicates (pagination stuck)")
+						m.atChannelStart = true
+						m.msgPaneDirty = true
 					} else {
 						log.Printf("olderMessagesMsg: no root posts and cannot fetch more (no channel or no messages)")
 					}
@@ -517,14 +1094,96 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		} else {
 			// Server returned empty - stop trying
 			log.Printf("olderMessagesMsg: server returned EMPTY - no more messages available")
+			m.atChannelStart = true
+			m.msgPaneDirty = true
+		}
+
+	case olderFailedMsg:
+		m.loadingOlder = false
+		m.reportError("load older messages", msg.err)
+		return m, nil
+
+	case usersFoundMsg:
+		m.addFoundUsers(msg)
+
+	case searchResultsMsg:
+		m.showResults(msg)
+
+	case authorFoundMsg:
+		return m, m.handleAuthorFound(msg)
+
+	case reactedMsg:
+		m.handleReacted(msg)
+		return m, nil
+
+	case pinnedPostsMsg:
+		m.handlePinnedPosts(msg)
+		return m, nil
+
+	case refreshChannelsMsg:
+		m.channelRefreshPending = false
+		return m, fetchChannels(m.platform)
+
+	case channelsLoadedMsg:
+		cmd := m.handleChannelsLoaded(msg)
+		if m.selectResumeChannel() {
+			next, openCmd := m.openSelected()
+			return next, tea.Batch(cmd, openCmd)
+		}
+		return m, cmd
+
+	case channelsMsg:
+		if m.loadingTeam != "" {
+			return m, nil // the switch brings a fresh list
+		}
+		m.replaceChannels(msg)
+		return m, m.nameGroups()
+
+	case groupMembersMsg:
+		m.setGroupName(msg)
+		return m, nil
+
+	case copiedMsg:
+		m.setFlash("copied")
+
+	case savedMsg:
+		m.notice = "saved " + msg.path
+		return m, nil
+
+	case commandResultMsg:
+		return m, m.handleCommandResult(msg)
+
+	case channelStatsMsg:
+		// Ignore stats for a channel we already switched away from
+		if m.current >= 0 && m.current < len(m.channels) && m.channels[m.current].ID == msg.channelID {
+			m.totalMessages = msg.total
 		}
 
 	case errMsg:
-		m.err = msg
+		if m.connected {
+			// Once connected, errors go to the error line with a hint
+			log.Printf("error: %v", msg)
+			m.showError(msg)
+		} else {
+			m.err = msg
+		}
 
 	case tickMsg:
 		// Toggle cursor visibility
 		m.cursorVisible = !m.cursorVisible
+		if m.loadingOlder {
+			m.spinnerFrame++
+		}
+		m.statusFlash = false
+		if !m.errUntil.IsZero() && time.Now().After(m.errUntil) {
+			m.err = nil
+			m.errUntil = time.Time{}
+		}
+		for userID, at := range m.typing {
+			if time.Since(at) > typingTimeout {
+				delete(m.typing, userID)
+			}
+		}
 		return m, tickCmd()
 	}
 
@@ -538,27 +1197,51 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 // Pike/Cox: extract keyboard handlers from Update to reduce function size
 // handleGlobalKeys handles keys that work regardless of focus
 func (m model) handleGlobalKeys(key string) (tea.Model, tea.Cmd, bool) {
-	switch key {
-	case "ctrl+c":
-		m.cancel()
-		if m.eventStream != nil {
-			m.eventStream.Close()
-		}
-		if m.platform != nil {
-			m.platform.Disconnect()
-			m.platform.Destroy()
+	// A key that types, such as ?, is typed into a prompt, and in the
+	// message area unless a message is selected and nothing is typed yet,
+	// so that a message can start with it
+	if printableKey(key) && (m.searching || m.authorPrompt || m.filtering || (m.mainFocused() && (m.input != "" || m.messageCursor < 0))) {
+		return m, nil, false
+	}
+
+	switch keys.action(ctxGeneral, key) {
+	case actQuit:
+		// With something typed or unsent, the first press only warns
+		if !m.config.noConfirm && !m.quitting && (m.input != "" || len(m.outbox) > 0) && time.Now().After(m.quitUntil) {
+			m.quitUntil = time.Now().Add(quitConfirmWindow)
+			m.notice = "press " + displayKey(key) + " again to quit"
+			return m, nil, true
 		}
-		comm.Cleanup()
-		return m, tea.Quit, true
+		return m, m.quit(), true
 
-	case "ctrl+b":
-		// Toggle focus between sidebar and main
-		if m.focus == focusSidebar {
-			m.focus = focusMain
-		} else {
+	case actFocus:
+		// Toggle focus between sidebar and main (or the open thread)
+		if m.focus != focusSidebar {
 			m.focus = focusSidebar
+		} else if m.threadRootID != "" {
+			m.focus = focusThread
+		} else {
+			m.focus = focusMain
 		}
 		return m, nil, true
+
+	case actToggleReplies:
+		// Toggle thread replies inline
+		m.toggleReplies()
+		return m, savePrefsCmd(m.prefs()), true
+
+	case actToggleSystem:
+		m.toggleSystem()
+		return m, savePrefsCmd(m.prefs()), true
+
+	case actHelp:
+		m.showHelp = true
+		m.helpScroll = 0
+		return m, nil, true
+
+	case actReconnect:
+		// Reconnect the event stream now
+		return m, m.retryConnection(), true
 	}
 	return m, nil, false
 }
@@ -569,107 +1252,352 @@ func (m model) handleSidebarKeys(key string) (tea.Model, tea.Cmd, bool) {
 		return m, nil, false
 	}
 
-	switch key {
-	case "up":
+	switch keys.action(ctxSidebar, key) {
+	case actFilter:
+		m.filtering = true
+		return m, nil, true
+
+	case actNarrow, actWiden:
+		// Narrow or widen the sidebar
+		width := m.sidebarWidth
+		if width == 0 {
+			width = m.layoutSidebarWidth()
+		}
+		if keys.action(ctxSidebar, key) == actNarrow {
+			width--
+		} else {
+			width++
+		}
+		m.sidebarWidth = max(minSidebarWidth, min(width, maxSidebarWidth))
+		return m, savePrefsCmd(m.prefs()), true
+
+	case actSidebarUp:
 		m.navigateSidebar(-1)
 		return m, nil, true
 
-	case "down":
+	case actSidebarDown:
 		m.navigateSidebar(1)
 		return m, nil, true
 
-	case " ":
-		if m.selectedType == navTeam {
-			// Select team with space key
-			if m.selected >= 0 && m.selected < len(m.teams) {
-				m.currentTeam = m.selected
-				m.teamSelected = true
-				// Clear messages and input
-				m.messages = nil
-				m.input = ""
-				m.cursorPos = 0
-				m.displayMsgsDirty = true // Invalidate message cache
-				m.navItemsDirty = true    // Invalidate nav cache (channels will change)
-				// Set team ID in platform and refresh channels
-				if err := m.platform.SetTeamID(m.teams[m.currentTeam].ID); err != nil {
-					m.err = fmt.Errorf("SetTeamID error: %w", err)
-					return m, nil, true
-				}
-				channels, err := m.platform.GetChannels()
-				if err != nil {
-					m.err = fmt.Errorf("GetChannels error: %w", err)
-					return m, nil, true
-				}
-				m.channels = channels
-				m.current = -1
-				// Move cursor to first channel if available
-				items := m.getNavItems()
-				for _, item := range items {
-					if item.itemType == navChannel || item.itemType == navDM {
-						m.selected = item.index
-						m.selectedType = item.itemType
-						break
-					}
-				}
-				if len(channels) == 0 {
-					m.err = fmt.Errorf("Warning: GetChannels returned 0 channels for team %s (%s)", m.teams[m.currentTeam].DisplayName, m.teams[m.currentTeam].ID)
-				}
-			}
-		} else if m.selectedType == navChannel || m.selectedType == navDM {
-			// Select channel/DM with space key
-			if m.selected >= 0 && m.selected < len(m.channels) {
-				m.current = m.selected
-				log.Printf("User selected channel: %s (ID=%s)", m.channels[m.current].DisplayName, m.channels[m.current].ID)
-				m.scrollOffset = 0       // Reset scroll
-				m.messageCursor = -1     // Reset message cursor
-				m.displayMsgsDirty = true // Invalidate message cache
-				// Clear messages and input when switching channel
-				m.messages = nil
-				m.input = ""
-				m.cursorPos = 0
-				// Switch focus to main area
-				m.focus = focusMain
-				return m, fetchMessages(m.platform, m.channels[m.current].ID), true
-			}
+	case actCollapse:
+		m.toggleSection()
+		return m, nil, true
+
+	case actOpen:
+		newModel, cmd := m.openSelected()
+		return newModel, cmd, true
+
+	case actOpenNumber:
+		// Open the channel shown with this number, as if selected and
+		// opened
+		items := m.getNavItems()
+		pos := keys.index(actOpenNumber, key)
+		start, end := sectionWindow(m.navDMStart-m.navChannelStart, m.sidebarScroll, maxChannelsDisplay)
+		if !m.teamSelected || pos < start || pos >= end {
+			return m, nil, true
+		}
+		item := items[m.navChannelStart+pos]
+		if item.index < 0 {
+			return m, nil, true // collapsed
+		}
+		m.selected = item.index
+		m.selectedType = item.itemType
+		newModel, cmd := m.openSelected()
+		return newModel, cmd, true
+	}
+
+	if keys.action(ctxGeneral, key) == actCancel {
+		if m.sidebarFilter != "" {
+			m.setSidebarFilter("")
 		}
 		return m, nil, true
 	}
 	return m, nil, false
 }
 
-// handleMainKeys handles keyboard input when main area is focused
-func (m model) handleMainKeys(key string) (tea.Model, tea.Cmd, bool) {
-	if m.focus != focusMain {
+// openSelected switches to the team, channel or DM under the sidebar
+// cursor, or expands the collapsed section it is on
+func (m model) openSelected() (tea.Model, tea.Cmd) {
+	if m.selected < 0 {
+		// On the header of a collapsed section
+		m.toggleSection()
+		return m, nil
+	}
+	if m.selectedType == navTeam {
+		// Select team
+		if m.selected >= 0 && m.selected < len(m.teams) {
+			saveCmd := m.leaveChannel()
+			m.currentTeam = m.selected
+			m.teamSelected = true
+			// Clear messages and input
+			m.messages = nil
+			m.input = ""
+			m.cursorPos = 0
+			m.displayMsgsDirty = true // Invalidate message cache
+			m.navItemsDirty = true    // Invalidate nav cache (channels will change)
+			m.sidebarScroll, m.dmScroll = 0, 0
+			// The old team's channels go at once; the new team's arrive
+			// in a channelsLoadedMsg
+			m.channels = nil
+			m.current = -1
+			m.loadingTeam = m.teams[m.currentTeam].ID
+			m.notice = "loading channels…"
+			return m, tea.Batch(saveCmd, loadTeamChannels(m.platform, m.loadingTeam))
+		}
+	} else if m.selectedType == navChannel || m.selectedType == navDM {
+		// Select channel/DM
+		if m.selected >= 0 && m.selected < len(m.channels) {
+			saveCmd := m.leaveChannel()
+			m.current = m.selected
+			m.authorFilter, m.authorName = "", "" // per channel
+			if m.rememberChannel() {
+				saveCmd = saveStateCmd(m.readState) // with what leaveChannel saves
+			}
+			m.restoreRead = true
+			m.newSince = ""
+			m.threadRootID = "" // Leave any open thread
+			delete(m.unread, m.channels[m.current].ID)
+			m.typing = nil
+			log.Printf("User selected channel: %s (ID=%s)", m.channels[m.current].DisplayName, m.channels[m.current].ID)
+			m.scrollOffset = 0        // Reset scroll
+			m.messageCursor = -1      // Reset message cursor
+			m.displayMsgsDirty = true // Invalidate message cache
+			m.totalMessages = -1      // Unknown until stats arrive
+			m.loadingOlder = false    // a page for the old channel is of no use
+			m.atChannelStart = false
+			// Clear messages and input when switching channel
+			m.messages = nil
+			m.input = ""
+			m.cursorPos = 0
+			// Switch focus to main area
+			m.focus = focusMain
+			channelID := m.channels[m.current].ID
+			cmds := []tea.Cmd{
+				saveCmd,
+				fetchMessages(m.platform, channelID),
+				fetchChannelMembers(m.platform, channelID),
+				markChannelRead(m.platform, channelID),
+				fetchChannelStats(m.platform, channelID),
+			}
+			if partner := m.dmPartner(m.channels[m.current]); partner != "" {
+				cmds = append(cmds, fetchUserStatus(m.platform, partner))
+			}
+			return m, tea.Batch(cmds...)
+		}
+	}
+	return m, nil
+}
+
+// mainFocused reports whether the main area (channel or thread) has focus
+func (m model) mainFocused() bool {
+	return m.focus == focusMain || m.focus == focusThread
+}
+
+// handleMessageKeys handles single-key commands on the selected message.
+// They only apply while a message is selected (messageCursor >= 0); with
+// no selection the same keys type into the input.
+func (m model) handleMessageKeys(key string) (tea.Model, tea.Cmd, bool) {
+	if !m.mainFocused() || m.messageCursor < 0 {
+		return m, nil, false
+	}
+	displayMsgs := m.getDisplayMessages()
+	if m.messageCursor >= len(displayMsgs) {
 		return m, nil, false
 	}
+	selected := displayMsgs[m.messageCursor]
 
-	switch key {
-	case "enter":
-		// Send message
-		if m.input == "" || !m.connected || len(m.channels) == 0 || m.current < 0 {
+	switch keys.action(ctxMessage, key) {
+	case actThread:
+		// Open the thread the selected message belongs to
+		if m.threadRootID != "" {
 			return m, nil, true
 		}
-		channelID := m.channels[m.current].ID
-		if _, err := m.platform.SendMessage(channelID, m.input); err != nil {
-			m.err = err
+		rootID := m.replyRoot(selected)
+		if rootID == "" {
+			rootID = selected.ID
 		}
-		m.input = ""
-		m.cursorPos = 0
-		return m, fetchMessages(m.platform, channelID), true
-
-	case "up":
-		displayMsgs := m.getDisplayMessages()
-		if len(displayMsgs) == 0 {
+		m.enterThread(rootID)
+		return m, nil, true
+	case actOldest:
+		m.jumpToOldest()
+		return m, nil, true
+	case actNewest:
+		return m, m.jumpToLatest(), true
+	case actCopy:
+		return m, copyToClipboard(selected.Text), true
+	case actPin:
+		m.togglePin(selected)
+		return m, nil, true
+	case actReply:
+		// Send again a message that failed to send, or reply to it
+		if cmd := m.resend(selected.ID); cmd != nil {
+			return m, cmd, true
+		}
+		m.reply(selected)
+		return m, nil, true
+	case actOpenLink:
+		// Repeated presses on the same message cycle through its links
+		urls := findURLs(selected.Text)
+		if len(urls) == 0 {
+			m.notice = "no links in message"
 			return m, nil, true
 		}
-		if m.messageCursor == -1 {
-			// Start from the last visible message
-			totalMsgs := len(displayMsgs)
-			end := totalMsgs - m.scrollOffset
-			if end > 0 {
-				m.messageCursor = end - 1
-			}
-			// Ensure cursor is in valid range
+		if selected.ID == m.urlMsgID {
+			m.urlIndex = (m.urlIndex + 1) % len(urls)
+		} else {
+			m.urlMsgID = selected.ID
+			m.urlIndex = 0
+		}
+		url := urls[m.urlIndex]
+		m.notice = fmt.Sprintf("opening %s (%d/%d)", url, m.urlIndex+1, len(urls))
+		return m, openURL(url), true
+	case actSave:
+		return m, m.saveAttachment(selected), true
+	case actPinned:
+		return m, m.showPinnedPosts(), true
+	case actReact:
+		m.openPicker(selected)
+		return m, nil, true
+	}
+	return m, nil, false
+}
+
+// copyToClipboard copies text to the system clipboard. A missing clipboard
+// is reported like any other failed operation, never fatal.
+func copyToClipboard(text string) tea.Cmd {
+	return func() tea.Msg {
+		if err := clipboard.WriteAll(text); err != nil {
+			return errMsg(&opError{op: "copy message", err: err})
+		}
+		return copiedMsg{}
+	}
+}
+
+// openURL opens url in the browser without blocking the UI
+func openURL(url string) tea.Cmd {
+	return func() tea.Msg {
+		if err := open.URL(url); err != nil {
+			return errMsg(&opError{op: "open link", err: err})
+		}
+		return nil
+	}
+}
+
+// saveInBackground runs save off the UI goroutine. Failing to save is
+// logged, as what, but never interrupts the session.
+func saveInBackground(what string, save func() error) tea.Cmd {
+	return func() tea.Msg {
+		if err := save(); err != nil {
+			log.Printf("%s: %v", what, err)
+		}
+		return nil
+	}
+}
+
+// setFlash shows status briefly in the input line
+func (m *model) setFlash(status string) {
+	m.flash = status
+	m.flashUntil = time.Now().Add(flashDuration)
+}
+
+// enterThread replaces the channel view with the thread rooted at rootID.
+// Loaded history is contiguous from the oldest loaded message to now, so
+// every reply to a loaded root is already in m.messages.
+func (m *model) enterThread(rootID string) {
+	m.channelScroll = m.scrollOffset
+	m.channelCursor = m.messageCursor
+	m.threadRootID = rootID
+	m.focus = focusThread
+	m.displayMsgsDirty = true // Invalidate message cache
+	m.scrollOffset = 0
+	m.messageCursor = -1
+}
+
+// exitThread returns to the channel view at the position it was left
+func (m *model) exitThread() {
+	m.threadRootID = ""
+	m.focus = focusMain
+	m.displayMsgsDirty = true // Invalidate message cache
+	m.scrollOffset = m.clampScrollOffset(m.channelScroll)
+	m.messageCursor = min(m.channelCursor, len(m.getDisplayMessages())-1)
+}
+
+// handleMainKeys handles keyboard input when main area is focused
+func (m model) handleMainKeys(key string) (tea.Model, tea.Cmd, bool) {
+	if !m.mainFocused() {
+		return m, nil, false
+	}
+
+	switch keys.action(ctxMain, key) {
+	case actSend:
+		// Send message, as a reply when a thread is open
+		if m.input == "" || !m.connected || len(m.channels) == 0 || m.current < 0 {
+			return m, nil, true
+		}
+		channelID := m.channels[m.current].ID
+		if m.editingMessageID != "" {
+			cmd := editMessage(m.platform, m.editingMessageID, m.input)
+			m.editingMessageID = ""
+			m.input = ""
+			m.cursorPos = 0
+			return m, cmd, true
+		}
+		if query, ok := searchCommand(m.input); ok {
+			m.remember(m.input)
+			m.input = ""
+			m.cursorPos = 0
+			return m, m.startSearch(query), true
+		}
+		if isSlashCommand(m.input) {
+			// /me, /shrug, /away... run on the server; show what it says
+			cmd := executeCommand(m.platform, channelID, m.input)
+			m.remember(m.input)
+			m.input = ""
+			m.cursorPos = 0
+			return m, cmd, true
+		}
+		// Shown at once as "sending…"; the outbox keeps the text until
+		// the server has it
+		cmd := m.queueSend(channelID, m.threadRootID, m.input)
+		m.remember(m.input)
+		m.input = ""
+		m.cursorPos = 0
+		return m, cmd, true
+
+	case actEditLast:
+		// Edit my most recent message shown here
+		if m.input != "" || m.editingMessageID != "" {
+			return m, nil, true
+		}
+		displayMsgs := m.getDisplayMessages()
+		for i := len(displayMsgs) - 1; i >= 0; i-- {
+			if m.myUserID != "" && displayMsgs[i].SenderID == m.myUserID && !m.isPending(displayMsgs[i].ID) && !isSystemMessage(displayMsgs[i]) {
+				m.editingMessageID = displayMsgs[i].ID
+				m.editSavedInput = m.input
+				m.input = displayMsgs[i].Text
+				m.cursorPos = len([]rune(m.input))
+				return m, nil, true
+			}
+		}
+		m.notice = "no message of yours to edit here"
+		return m, nil, true
+
+	case actScrollUp:
+		if m.recallHistory(1) {
+			return m, nil, true
+		}
+		displayMsgs := m.getDisplayMessages()
+		if len(displayMsgs) == 0 {
+			return m, nil, true
+		}
+		if m.messageCursor == -1 {
+			// Start from the last visible message
+			totalMsgs := len(displayMsgs)
+			end := totalMsgs - m.scrollOffset
+			if end > 0 {
+				m.messageCursor = end - 1
+			}
+			// Ensure cursor is in valid range
 			if m.messageCursor < 0 {
 				m.messageCursor = 0
 			}
@@ -687,18 +1615,20 @@ func (m model) handleMainKeys(key string) (tea.Model, tea.Cmd, bool) {
 			if m.scrollOffset < m.maxScroll() {
 				// Can scroll up to show older messages that are already loaded
 				m.scrollOffset = m.clampScrollOffset(m.scrollOffset + 1)
-			} else if m.scrollOffset >= m.maxScroll() && len(m.messages) > 0 && m.current >= 0 && m.current < len(m.channels) {
+			} else if m.scrollOffset >= m.maxScroll() && m.threadRootID == "" && len(m.messages) > 0 && m.current >= 0 && m.current < len(m.channels) {
 				// At max scroll - try to fetch older messages from server
 				// Cursor stays at 0, will only move if server returns root posts
 				log.Printf("up arrow: fetching older messages (at top)")
-				oldestMsg := m.messages[0]
-				return m, fetchOlderMessages(m.platform, m.channels[m.current].ID, oldestMsg.ID), true
+				return m, m.loadOlder(), true
 			}
 			// If already at absolute top, do nothing (keep cursor at 0, visible)
 		}
 		return m, nil, true
 
-	case "down":
+	case actScrollDown:
+		if m.recallHistory(-1) {
+			return m, nil, true
+		}
 		displayMsgs := m.getDisplayMessages()
 		if len(displayMsgs) == 0 {
 			return m, nil, true
@@ -723,9 +1653,9 @@ func (m model) handleMainKeys(key string) (tea.Model, tea.Cmd, bool) {
 			// If at newest message (scrollOffset == 0), stay on current message
 			// New messages are handled by real-time events
 		}
-		return m, nil, true
+		return m, m.loadNewer(), true
 
-	case "pgup":
+	case actPageUp:
 		displayMsgs := m.getDisplayMessages()
 		if len(displayMsgs) == 0 {
 			return m, nil, true
@@ -758,14 +1688,13 @@ func (m model) handleMainKeys(key string) (tea.Model, tea.Cmd, bool) {
 		m.ensureCursorVisible()
 
 		// If near top, proactively fetch older messages
-		if m.messageCursor < messagePrefetchBuffer && len(m.messages) > 0 && m.current >= 0 && m.current < len(m.channels) {
+		if m.messageCursor < messagePrefetchBuffer && m.threadRootID == "" && len(m.messages) > 0 && m.current >= 0 && m.current < len(m.channels) {
 			log.Printf("pgup: fetching older messages (near top)")
-			oldestMsg := m.messages[0]
-			return m, fetchOlderMessages(m.platform, m.channels[m.current].ID, oldestMsg.ID), true
+			return m, m.loadOlder(), true
 		}
 		return m, nil, true
 
-	case "pgdown":
+	case actPageDown:
 		displayMsgs := m.getDisplayMessages()
 		if len(displayMsgs) == 0 {
 			return m, nil, true
@@ -796,11 +1725,49 @@ func (m model) handleMainKeys(key string) (tea.Model, tea.Cmd, bool) {
 
 		// Ensure cursor visible
 		m.ensureCursorVisible()
+		return m, m.loadNewer(), true
+
+	case actNewline:
+		// Ctrl+Enter adds newline in typing section
+		runes := []rune(m.input)
+		m.input = string(runes[:m.cursorPos]) + "\n" + string(runes[m.cursorPos:])
+		m.cursorPos++
+		m.historyIndex = -1
 		return m, nil, true
+	}
+
+	if keys.action(ctxGeneral, key) == actCancel {
+		// Cancel an edit first, then leave the thread view
+		if m.editingMessageID != "" {
+			m.editingMessageID = ""
+			m.input = m.editSavedInput
+			m.cursorPos = len([]rune(m.input))
+			return m, nil, true
+		}
+		if m.focus == focusThread {
+			m.exitThread()
+			return m, nil, true
+		}
+		return m, nil, false
+	}
 
-	case "backspace", "ctrl+h":
-		// Backspace removes character in typing section
+	// With nothing typed, the oldest and newest keys that do not type,
+	// Home and End, work with no message selected too
+	if m.input == "" && !printableKey(key) {
+		switch keys.action(ctxMessage, key) {
+		case actOldest:
+			m.jumpToOldest()
+			return m, nil, true
+		case actNewest:
+			return m, m.jumpToLatest(), true
+		}
+	}
+
+	// Editing the input line
+	switch keys.action(ctxInput, key) {
+	case actDeleteBack:
 		// Some terminals send "backspace", others send "ctrl+h"
+		m.historyIndex = -1
 		if len(m.input) > 0 && m.cursorPos > 0 {
 			// Handle UTF-8 correctly by converting to runes
 			runes := []rune(m.input)
@@ -811,97 +1778,662 @@ func (m model) handleMainKeys(key string) (tea.Model, tea.Cmd, bool) {
 		}
 		return m, nil, true
 
-	case "ctrl+enter", "ctrl+m":
-		// Ctrl+Enter adds newline in typing section
-		runes := []rune(m.input)
-		m.input = string(runes[:m.cursorPos]) + "\n" + string(runes[m.cursorPos:])
-		m.cursorPos++
+	case actCursorLeft:
+		m.cursorPos = max(m.cursorPos-1, 0)
 		return m, nil, true
 
-	case " ":
-		// In main area, space is part of input
-		m.input += " "
-		m.cursorPos++
+	case actCursorRight:
+		m.cursorPos = min(m.cursorPos+1, len([]rune(m.input)))
+		return m, nil, true
+
+	case actLineStart:
+		m.cursorPos = 0
+		return m, nil, true
+
+	case actLineEnd:
+		m.cursorPos = len([]rune(m.input))
+		return m, nil, true
+
+	case actWordLeft:
+		m.cursorPos = wordLeft([]rune(m.input), m.cursorPos)
+		return m, nil, true
+
+	case actWordRight:
+		m.cursorPos = wordRight([]rune(m.input), m.cursorPos)
+		return m, nil, true
+
+	case actDeleteWord, actDeleteToStart, actDeleteToEnd:
+		// Delete the word before the cursor, or everything before or
+		// after it
+		runes := []rune(m.input)
+		from, to := wordLeft(runes, m.cursorPos), m.cursorPos
+		switch keys.action(ctxInput, key) {
+		case actDeleteToStart:
+			from = 0
+		case actDeleteToEnd:
+			from, to = m.cursorPos, len(runes)
+		}
+		m.input = string(runes[:from]) + string(runes[to:])
+		m.cursorPos = from
+		m.historyIndex = -1
 		return m, nil, true
 	}
 	return m, nil, false
 }
 
-// handleInputChar handles regular character input in main area
-func (m model) handleInputChar(str string) (tea.Model, tea.Cmd, bool) {
-	if m.focus != focusMain {
+// isSlashCommand reports whether input is a server command such as
+// "/me waves". A lone "/" is sent as text.
+func isSlashCommand(input string) bool {
+	return len(input) > 1 && input[0] == '/' && input[1] != ' '
+}
+
+// remember adds a sent message to the input history
+func (m *model) remember(text string) {
+	m.historyIndex = -1
+	if n := len(m.sentHistory); n > 0 && m.sentHistory[n-1] == text {
+		return
+	}
+	m.sentHistory = append(m.sentHistory, text)
+	if len(m.sentHistory) > maxSentHistory {
+		m.sentHistory = m.sentHistory[len(m.sentHistory)-maxSentHistory:]
+	}
+}
+
+// recallHistory moves through sent messages like a shell: delta 1 goes to
+// an older one, -1 to a newer one, past the newest back to an empty input.
+// It only applies with no message selected and the input empty or showing
+// a recalled entry, and reports whether it handled the key. Up past the
+// oldest entry is left to select messages.
+func (m *model) recallHistory(delta int) bool {
+	if m.messageCursor != -1 || (m.input != "" && m.historyIndex < 0) {
+		return false
+	}
+	next := m.historyIndex + delta
+	if next >= len(m.sentHistory) || (next < 0 && m.historyIndex < 0) {
+		return false
+	}
+	m.historyIndex = next
+	m.input = ""
+	if next >= 0 {
+		m.input = m.sentHistory[len(m.sentHistory)-1-next]
+	}
+	m.cursorPos = len([]rune(m.input))
+	return true
+}
+
+// handleInputChar handles regular character input in main area: any
+// printable characters, accented letters, CJK and emoji too, several at
+// once from an input method
+func (m model) handleInputChar(msg tea.KeyMsg) (tea.Model, tea.Cmd, bool) {
+	if !m.mainFocused() {
 		return m, nil, false
 	}
 
-	// Ignore ctrl and alt combinations
-	if strings.HasPrefix(str, "ctrl+") || strings.HasPrefix(str, "alt+") {
+	// Ignore named keys and alt combinations
+	if (msg.Type != tea.KeyRunes && msg.Type != tea.KeySpace) || msg.Alt || len(msg.Runes) == 0 {
 		return m, nil, false
 	}
+	for _, r := range msg.Runes {
+		if !unicode.IsPrint(r) {
+			return m, nil, false
+		}
+	}
 
-	// Only add single printable characters
-	if len(str) == 1 && str[0] >= printableCharMin && str[0] <= printableCharMax {
-		runes := []rune(m.input)
-		m.input = string(runes[:m.cursorPos]) + str + string(runes[m.cursorPos:])
-		m.cursorPos++
-		return m, nil, true
+	runes := []rune(m.input)
+	m.input = string(runes[:m.cursorPos]) + string(msg.Runes) + string(runes[m.cursorPos:])
+	m.cursorPos += len(msg.Runes)
+	m.historyIndex = -1
+	return m, m.sendTyping(), true
+}
+
+// sendTyping tells the server we are typing in the current channel, at
+// most once per typingSendInterval
+func (m *model) sendTyping() tea.Cmd {
+	if m.input == "" || !m.connected || m.current < 0 || m.current >= len(m.channels) {
+		return nil
+	}
+	if time.Since(m.lastTypingSent) < typingSendInterval {
+		return nil
+	}
+	m.lastTypingSent = time.Now()
+	platform, channelID := m.platform, m.channels[m.current].ID
+	return func() tea.Msg {
+		// Only a courtesy to others; not worth a notice if it fails
+		if err := platform.SendTyping(channelID); err != nil {
+			log.Printf("send typing: %v", err)
+		}
+		return nil
 	}
-	return m, nil, false
 }
 
-func fetchMessages(platform *comm.Platform, channelID string) tea.Cmd {
+func fetchMessages(platform platformAPI, channelID string) tea.Cmd {
 	return func() tea.Msg {
 		log.Printf("fetchMessages: requesting initial messages for channel %s", channelID)
 		messages, err := platform.GetMessages(channelID, messageFetchLimit)
 		if err != nil {
 			log.Printf("fetchMessages: error: %v", err)
-			return errMsg(err)
+			return errMsg(&opError{op: "load messages", err: err})
 		}
 		log.Printf("fetchMessages: received %d messages", len(messages))
 		return messagesMsg(messages)
 	}
 }
 
-func fetchOlderMessages(platform *comm.Platform, channelID, beforeID string) tea.Cmd {
+// fetchChannelMembers loads the members of a channel, so its senders are
+// named from the cache rather than looked up one by one. On failure they
+// still are, so the error is only logged.
+func fetchChannelMembers(platform platformAPI, channelID string) tea.Cmd {
+	return func() tea.Msg {
+		members, err := platform.GetChannelMembers(channelID)
+		if err != nil {
+			log.Printf("fetchChannelMembers: error: %v", err)
+			return nil
+		}
+		log.Printf("fetchChannelMembers: received %d members", len(members))
+		return channelMembersMsg(members)
+	}
+}
+
+func fetchOlderMessages(platform platformAPI, channelID, beforeID string) tea.Cmd {
 	return func() tea.Msg {
 		log.Printf("fetchOlderMessages: requesting messages before ID=%s", beforeID)
 		messages, err := platform.GetMessagesBefore(channelID, beforeID, messageFetchLimit)
 		if err != nil {
-			log.Printf("fetchOlderMessages: error: %v", err)
-			return errMsg(err)
+			return olderFailedMsg{err: err}
 		}
 		log.Printf("fetchOlderMessages: received %d messages", len(messages))
 		return olderMessagesMsg(messages)
 	}
 }
 
-func fetchMessage(platform *comm.Platform, messageID string) tea.Cmd {
+// eventMessageID returns the message ID an event refers to.
+// Try MessageID first, then extract from Data if needed.
+func eventMessageID(event *comm.Event) string {
+	if event.MessageID != "" {
+		return event.MessageID
+	}
+	if dataMap, ok := event.Data.(map[string]interface{}); ok {
+		if id, ok := dataMap["id"].(string); ok {
+			return id
+		}
+	}
+	return ""
+}
+
+// countUnread counts a posted message against its channel unless that
+// channel is open or the message is our own
+func (m *model) countUnread(event *comm.Event) {
+	if event.ChannelID == "" || (m.myUserID != "" && event.UserID == m.myUserID) {
+		return
+	}
+	if m.current >= 0 && m.current < len(m.channels) && m.channels[m.current].ID == event.ChannelID {
+		return
+	}
+	if m.unread == nil {
+		m.unread = make(map[string]int)
+	}
+	m.unread[event.ChannelID]++
+}
+
+// removeMessage drops a deleted message, keeping the scroll position and
+// the message cursor on the same neighbours. If the cursor was on the
+// deleted message it moves to the next one (or the previous at the end).
+func (m *model) removeMessage(id string) {
+	displayMsgs := m.getDisplayMessages()
+	totalBefore := len(displayMsgs)
+	deletedIdx := -1
+	for i, msg := range displayMsgs {
+		if msg.ID == id {
+			deletedIdx = i
+			break
+		}
+	}
+
+	kept := make([]comm.Message, 0, len(m.messages))
+	for _, msg := range m.messages {
+		if msg.ID != id {
+			kept = append(kept, msg)
+		}
+	}
+	if id == m.pinnedMessageID {
+		m.unpin()
+	}
+	if len(kept) == len(m.messages) {
+		return // not loaded here
+	}
+	m.messages = kept
+	delete(m.edited, id)
+	m.displayMsgsDirty = true // Invalidate cache
+	total := len(m.getDisplayMessages())
+
+	if deletedIdx >= 0 {
+		// Messages above the cursor shift it up by one
+		if m.messageCursor > deletedIdx {
+			m.messageCursor--
+		}
+		// Scrolled-past messages below the view shrink the offset
+		if deletedIdx >= totalBefore-m.scrollOffset {
+			m.scrollOffset--
+		}
+	}
+	if m.messageCursor >= total {
+		m.messageCursor = total - 1
+	}
+	m.scrollOffset = m.clampScrollOffset(m.scrollOffset)
+	if m.messageCursor >= 0 {
+		m.ensureCursorVisible()
+	}
+}
+
+// fetchEditedMessage refetches a message after an edit event
+func fetchEditedMessage(platform platformAPI, messageID string) tea.Cmd {
 	return func() tea.Msg {
 		msg, err := platform.GetMessage(messageID)
 		if err != nil {
-			return errMsg(err)
+			return errMsg(&opError{op: "load edited message", err: err})
+		}
+		return editedMessageMsg(*msg)
+	}
+}
+
+// editMessage replaces the text of one of our messages
+func editMessage(platform platformAPI, messageID, text string) tea.Cmd {
+	return func() tea.Msg {
+		msg, err := platform.EditMessage(messageID, text)
+		if err != nil {
+			return errMsg(&opError{op: "edit message", err: err})
+		}
+		return editedMessageMsg(*msg)
+	}
+}
+
+// executeCommand runs a slash command on the server without blocking the
+// UI, which a re-login behind the platform could otherwise do for minutes
+func executeCommand(platform platformAPI, channelID, command string) tea.Cmd {
+	return func() tea.Msg {
+		result, err := platform.ExecuteCommand(channelID, command)
+		return commandResultMsg{channelID: channelID, command: command, result: result, err: err}
+	}
+}
+
+// handleCommandResult shows what a slash command said, or why it failed,
+// and reloads the channel it ran in, where it may have posted
+func (m *model) handleCommandResult(msg commandResultMsg) tea.Cmd {
+	if msg.err != nil {
+		m.reportError(strings.Fields(msg.command)[0], msg.err)
+	} else if msg.result != "" {
+		m.notice = strings.Join(strings.Fields(msg.result), " ")
+	}
+	if m.current < 0 || m.current >= len(m.channels) || m.channels[m.current].ID != msg.channelID {
+		return nil
+	}
+	return fetchMessages(m.platform, msg.channelID)
+}
+
+func fetchMessage(platform platformAPI, messageID string) tea.Cmd {
+	return func() tea.Msg {
+		msg, err := platform.GetMessage(messageID)
+		if err != nil {
+			return errMsg(&opError{op: "load message", err: err})
 		}
 		return newMessageMsg(*msg)
 	}
 }
 
-// getDisplayMessages returns messages to display (filters thread replies)
+// alertMention flashes the status bar, and with it rings the terminal
+// bell (see View), when msg mentions us in a channel we are not looking
+// at. Only with -bell.
+func (m *model) alertMention(msg comm.Message) {
+	if !m.config.bell || msg.SenderID == m.myUserID || !mentionsMe(msg.Text, m.myUsername) {
+		return
+	}
+	if m.current >= 0 && m.current < len(m.channels) && m.channels[m.current].ID == msg.ChannelID {
+		return
+	}
+	m.statusFlash = true
+}
+
+// markChannelRead tells the server the channel has been read, so other
+// clients stop notifying about it
+func markChannelRead(platform platformAPI, channelID string) tea.Cmd {
+	return func() tea.Msg {
+		if err := platform.MarkChannelRead(channelID); err != nil {
+			return errMsg(&opError{op: "mark channel read", err: err})
+		}
+		return nil
+	}
+}
+
+// fetchChannels refetches the current team's channels
+func fetchChannels(platform platformAPI) tea.Cmd {
+	return func() tea.Msg {
+		teamSwitch.Lock() // not halfway through a team switch
+		channels, err := platform.GetChannels()
+		teamSwitch.Unlock()
+		if err != nil {
+			return errMsg(&opError{op: "refresh channels", err: err})
+		}
+		return channelsMsg(channels)
+	}
+}
+
+// replaceChannels swaps in a refetched channel list. Indices into the old
+// list are re-resolved by ID, so the open channel and the sidebar cursor
+// stay put; if the open channel was deleted nothing is open.
+func (m *model) replaceChannels(channels []comm.Channel) {
+	indexOf := func(i int) int {
+		if i < 0 || i >= len(m.channels) {
+			return -1
+		}
+		for j, ch := range channels {
+			if ch.ID == m.channels[i].ID {
+				return j
+			}
+		}
+		return -1
+	}
+	current := indexOf(m.current)
+	if current < 0 && m.current >= 0 {
+		m.messages = nil
+		m.threadRootID = ""
+		m.displayMsgsDirty = true
+		if m.focus == focusThread {
+			m.focus = focusMain
+		}
+	}
+	if m.selectedType != navTeam && m.selected >= 0 {
+		if m.selected = indexOf(m.selected); m.selected < 0 {
+			m.selected, m.selectedType = m.currentTeam, navTeam
+		}
+	}
+	m.current = current
+	m.channels = channels
+	m.navItemsDirty = true
+}
+
+// fetchChannelStats fetches the server-side message count for a channel.
+// Failure is not an error for the user: the status line falls back to
+// showing only the loaded count.
+func fetchChannelStats(platform platformAPI, channelID string) tea.Cmd {
+	return func() tea.Msg {
+		stats, err := platform.GetChannelStats(channelID)
+		if err != nil || stats == nil {
+			log.Printf("fetchChannelStats: unavailable for %s: %v", channelID, err)
+			return channelStatsMsg{channelID: channelID, total: -1}
+		}
+		return channelStatsMsg{channelID: channelID, total: stats.MessageCount}
+	}
+}
+
+// getDisplayMessages returns messages to display. Thread replies are
+// filtered out, or with showReplies placed directly after their root post.
 // Pike/Cox: cache filtered results to avoid repeated allocations
 func (m *model) getDisplayMessages() []comm.Message {
 	if !m.displayMsgsDirty {
 		return m.displayMsgsCache
 	}
-	// Filter thread replies in both channels and DMs
-	filtered := make([]comm.Message, 0, len(m.messages))
+	m.replyCounts = make(map[string]int)
+	m.messageIDs = make(map[string]bool, len(m.messages))
+	rootIDs := make(map[string]string, len(m.messages))
 	for _, msg := range m.messages {
-		if !isThreadReply(msg) {
-			filtered = append(filtered, msg)
+		m.messageIDs[msg.ID] = true
+		rootID, ok := m.rootIDs[msg.ID]
+		if !ok {
+			rootID = threadRootID(msg)
+		}
+		rootIDs[msg.ID] = rootID
+		if rootID != "" {
+			m.replyCounts[rootID]++
+		}
+	}
+	m.rootIDs = rootIDs
+
+	var filtered []comm.Message
+	if m.threadRootID != "" {
+		// Thread view: the root and its replies in chronological order
+		for _, msg := range m.messages {
+			if msg.ID == m.threadRootID || m.rootIDs[msg.ID] == m.threadRootID {
+				filtered = append(filtered, msg)
+			}
+		}
+	} else if m.showReplies {
+		filtered = threadedMessages(m.messages, m.rootIDs)
+	} else {
+		// Filter thread replies in both channels and DMs
+		filtered = make([]comm.Message, 0, len(m.messages))
+		for _, msg := range m.messages {
+			if m.rootIDs[msg.ID] == "" {
+				filtered = append(filtered, msg)
+			}
 		}
 	}
+	if m.hideSystem {
+		kept := filtered[:0:0]
+		for _, msg := range filtered {
+			if !isSystemMessage(msg) {
+				kept = append(kept, msg)
+			}
+		}
+		filtered = kept
+	}
+	filtered = m.filterAuthor(filtered)
+	filtered = append(filtered, m.pendingMessages()...)
 	m.displayMsgsCache = filtered
 	m.displayMsgsDirty = false
+	m.msgPaneDirty = true
 	return filtered
 }
 
+// trimMessages trims the loaded messages to maxLoadedMessages: the newest
+// (after older ones were prepended) or the oldest (while following new
+// ones at the bottom). Only messages off screen are dropped, and the view
+// and message cursor stay on the same messages.
+func (m *model) trimMessages(dropNewest bool) {
+	n := len(m.messages) - maxLoadedMessages
+	if n <= 0 {
+		return
+	}
+	display := m.getDisplayMessages()
+	anchorID, cursorID := "", "" // last visible and selected message
+	if end := len(display) - m.scrollOffset; end > 0 && end <= len(display) {
+		anchorID = display[end-1].ID
+	}
+	if m.messageCursor >= 0 && m.messageCursor < len(display) {
+		cursorID = display[m.messageCursor].ID
+	}
+
+	// Loaded messages are oldest first; keep [from, to)
+	from, to := 0, len(m.messages)
+	if dropNewest {
+		to -= n
+	} else {
+		from = n
+	}
+	for i, msg := range m.messages {
+		if msg.ID == anchorID || msg.ID == cursorID {
+			from = min(from, i)
+			to = max(to, i+1)
+		}
+	}
+	if from == 0 && to == len(m.messages) {
+		return
+	}
+	log.Printf("trimMessages: keeping %d-%d of %d messages", from, to, len(m.messages))
+	if to < len(m.messages) {
+		m.newerTrimmed = true
+	}
+	if from > 0 {
+		m.atChannelStart = false // the oldest can be fetched again
+	}
+	// Copy, so the trimmed messages are not kept alive by the array
+	m.messages = append([]comm.Message(nil), m.messages[from:to]...)
+	m.displayMsgsDirty = true
+
+	display = m.getDisplayMessages()
+	for i, msg := range display {
+		if msg.ID == anchorID {
+			m.scrollOffset = len(display) - i - 1
+		}
+		if msg.ID == cursorID {
+			m.messageCursor = i
+		}
+	}
+	m.scrollOffset = m.clampScrollOffset(m.scrollOffset)
+}
+
+// jumpToOldest selects the oldest loaded message and scrolls to it
+func (m *model) jumpToOldest() {
+	if len(m.getDisplayMessages()) == 0 {
+		return
+	}
+	m.messageCursor = 0
+	m.ensureCursorVisible()
+}
+
+// jumpToLatest drops the selection and scrolls to the newest message,
+// reloading the newest messages if they were trimmed
+func (m *model) jumpToLatest() tea.Cmd {
+	m.messageCursor = -1
+	m.scrollOffset = 0
+	return m.loadNewer()
+}
+
+// loadOlder fetches the page of messages before the oldest loaded one,
+// unless a fetch is already in flight: whatever asked for this one goes on
+// once that page arrives
+func (m *model) loadOlder() tea.Cmd {
+	if m.loadingOlder || m.atChannelStart || len(m.messages) == 0 || m.current < 0 || m.current >= len(m.channels) {
+		return nil
+	}
+	m.loadingOlder = true
+	m.olderBefore = m.messages[0].ID
+	return fetchOlderMessages(m.platform, m.channels[m.current].ID, m.messages[0].ID)
+}
+
+// startsHistory reports whether msg is shown under the "beginning of
+// #channel" marker: the first message of the channel view once the server
+// has no older ones, or no more worth fetching
+func (m model) startsHistory(msg comm.Message) bool {
+	return m.atChannelStart && m.threadRootID == "" && m.current >= 0 && m.current < len(m.channels) && len(m.displayMsgsCache) > 0 && m.displayMsgsCache[0].ID == msg.ID
+}
+
+// historyStartMarker renders the dim "──── beginning of #general ────"
+// line above the oldest message of ch
+func historyStartMarker(ch comm.Channel, width int) string {
+	name := channelName(ch)
+	if !isDM(ch) {
+		name = "#" + name
+	}
+	label := " beginning of " + name + " "
+	side := max((width-lipgloss.Width(label))/2, 0)
+	line := strings.Repeat("─", side) + label + strings.Repeat("─", side)
+	return style.time.Render(fitWidth(line, width))
+}
+
+// spinnerFrames animate the loading line, one frame per tick
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// renderLoading renders the dim line shown above the messages while an
+// older page is being fetched
+func (m model) renderLoading(mainWidth int) string {
+	frame := spinnerFrames[m.spinnerFrame%len(spinnerFrames)]
+	return style.time.Render(fitWidth(" "+frame+" loading older messages…", mainWidth))
+}
+
+// loadNewer reloads the newest messages once scrolled back down to the
+// bottom after trimMessages dropped them
+func (m model) loadNewer() tea.Cmd {
+	if !m.newerTrimmed || m.scrollOffset > 0 || m.threadRootID != "" || m.current < 0 || m.current >= len(m.channels) {
+		return nil
+	}
+	return fetchMessages(m.platform, m.channels[m.current].ID)
+}
+
+// hasMessage reports whether the message with this ID is loaded. The ID
+// set is rebuilt with the display cache.
+func (m *model) hasMessage(id string) bool {
+	m.getDisplayMessages()
+	return m.messageIDs[id]
+}
+
+// threadReplyCount returns how many loaded replies the thread rooted at
+// rootID has. Counts are rebuilt with the display cache.
+func (m *model) threadReplyCount(rootID string) int {
+	m.getDisplayMessages()
+	return m.replyCounts[rootID]
+}
+
+// threadedMessages orders msgs so each root post is followed by its replies
+// in chronological order. Replies whose root is not loaded stay in place.
+func threadedMessages(msgs []comm.Message, rootIDs map[string]string) []comm.Message {
+	loaded := make(map[string]bool, len(msgs))
+	for _, msg := range msgs {
+		loaded[msg.ID] = true
+	}
+	replies := make(map[string][]comm.Message)
+	for _, msg := range msgs {
+		if rootID := rootIDs[msg.ID]; rootID != "" && loaded[rootID] {
+			replies[rootID] = append(replies[rootID], msg)
+		}
+	}
+
+	ordered := make([]comm.Message, 0, len(msgs))
+	for _, msg := range msgs {
+		if rootID := rootIDs[msg.ID]; rootID != "" && loaded[rootID] {
+			continue // emitted after its root
+		}
+		ordered = append(ordered, msg)
+		ordered = append(ordered, replies[msg.ID]...)
+	}
+	return ordered
+}
+
+// messageLineCount returns the number of screen lines msg occupies.
+// Rendering and all scroll math must agree on this.
+func messageLineCount(msg comm.Message) int {
+	if isSystemMessage(msg) {
+		return 1 // see renderSystemMessage
+	}
+	n := len(parseBody(msg.Text))
+	n += len(attachments(msg)) // one line per file
+	if len(reactions(msg)) > 0 {
+		n++ // reaction line
+	}
+	return n
+}
+
+// toggleReplies switches between hiding thread replies and showing them
+// inline, keeping the message cursor on the same message.
+func (m *model) toggleReplies() {
+	cursorID := m.cursorMessageID()
+	m.showReplies = !m.showReplies
+	m.displayMsgsDirty = true
+	m.reselect(cursorID)
+}
+
+// cursorMessageID returns the ID of the selected message, or ""
+func (m *model) cursorMessageID() string {
+	displayMsgs := m.getDisplayMessages()
+	if m.messageCursor >= 0 && m.messageCursor < len(displayMsgs) {
+		return displayMsgs[m.messageCursor].ID
+	}
+	return ""
+}
+
+// reselect puts the message cursor back on the message with this ID after
+// the display messages changed, deselecting if it is no longer shown
+func (m *model) reselect(cursorID string) {
+	displayMsgs := m.getDisplayMessages()
+	m.messageCursor = -1
+	for i, msg := range displayMsgs {
+		if msg.ID == cursorID {
+			m.messageCursor = i
+			break
+		}
+	}
+	m.ensureCursorVisible()
+}
+
 // ensureCursorVisible adjusts scroll offset to keep message cursor visible
 func (m *model) ensureCursorVisible() {
 	if m.messageCursor == -1 {
@@ -915,31 +2447,11 @@ func (m *model) ensureCursorVisible() {
 		return
 	}
 
-	msgHeight := m.msgHeight()
 	totalMsgs := len(displayMsgs)
 
 	// Calculate visible range using same logic as View()
-	// Work backward from end, counting screen lines
-	end := totalMsgs - m.scrollOffset
-	if end > totalMsgs {
-		end = totalMsgs
-	}
-	if end < 0 {
-		end = 0
-	}
-
-	linesUsed := 0
-	start := end
-	for start > 0 && linesUsed < msgHeight {
-		msgIdx := start - 1
-		msg := displayMsgs[msgIdx]
-		msgLines := len(strings.Split(msg.Text, "\n"))
-		if linesUsed+msgLines > msgHeight && linesUsed > 0 {
-			break
-		}
-		linesUsed += msgLines
-		start--
-	}
+	end := max(0, min(totalMsgs-m.scrollOffset, totalMsgs))
+	start, _ := m.visibleRange(displayMsgs, end, m.msgHeight())
 
 	// If cursor is above visible area, scroll up to show it
 	if m.messageCursor < start {
@@ -955,10 +2467,66 @@ func (m *model) ensureCursorVisible() {
 	m.scrollOffset = m.clampScrollOffset(m.scrollOffset)
 }
 
+// visibleRange returns the first message shown when the view ends just
+// before end, and the screen lines used. It works backward from end,
+// counting message lines plus date separators: one before each change of
+// day, and always one above the top message so its date is visible.
+// Rendering and all scroll math must use this.
+func (m model) visibleRange(displayMsgs []comm.Message, end, height int) (start, linesUsed int) {
+	start = end
+	for start > 0 {
+		// The new top message brings a separator; the previous top keeps
+		// its own only at a change of day
+		delta := m.messageLines(displayMsgs[start-1]) + 1
+		if start < end && !dateBreak(displayMsgs, start) {
+			delta--
+		}
+		if linesUsed+delta > height && linesUsed > 0 {
+			// This message won't fit, stop here
+			break
+		}
+		linesUsed += delta
+		start--
+	}
+	return start, linesUsed
+}
+
+// dateBreak reports whether msgs[i] starts a new calendar day
+func dateBreak(msgs []comm.Message, i int) bool {
+	if i == 0 {
+		return true
+	}
+	y1, m1, d1 := msgs[i-1].CreatedAt.Local().Date()
+	y2, m2, d2 := msgs[i].CreatedAt.Local().Date()
+	return y1 != y2 || m1 != m2 || d1 != d2
+}
+
+// dateSeparator renders the dim "──── Monday, Jan 6 ────" line
+func dateSeparator(t time.Time, width int) string {
+	layout := "Monday, Jan 2"
+	if t.Year() != time.Now().Year() {
+		layout = "Monday, Jan 2 2006"
+	}
+	label := " " + t.Local().Format(layout) + " "
+	side := max((width-lipgloss.Width(label))/2, 0)
+	line := strings.Repeat("─", side) + label + strings.Repeat("─", side)
+	return style.time.Render(fitWidth(line, width))
+}
+
 // msgHeight returns the height available for messages
 func (m model) msgHeight() int {
-	// Use actual terminal height, reserve 1 line for input
-	h := m.height - 1
+	// Use actual terminal height, reserve lines for topic, status bar and
+	// input, and for the error line while one is shown
+	h := m.height - topicHeight - statusHeight - 1
+	if m.showingError() {
+		h -= errorHeight
+	}
+	if m.loadingOlder {
+		h -= loadingHeight
+	}
+	if m.showingPin() {
+		h -= pinnedHeight
+	}
 	if h < minMessageHeight {
 		h = minMessageHeight
 	}
@@ -975,12 +2543,15 @@ func (m model) maxScroll() int {
 
 	msgHeight := m.msgHeight()
 
-	// Work forward from start, counting lines to see how many messages fit
+	// Work forward from start, counting lines to see how many messages fit.
+	// The first message always shows its date separator.
 	linesUsed := 0
 	msgsFit := 0
 	for i := 0; i < totalMsgs; i++ {
-		msg := displayMsgs[i]
-		msgLines := len(strings.Split(msg.Text, "\n"))
+		msgLines := m.messageLines(displayMsgs[i])
+		if i == 0 || dateBreak(displayMsgs, i) {
+			msgLines++
+		}
 		if linesUsed+msgLines > msgHeight && msgsFit > 0 {
 			// This message won't fit
 			break
@@ -1012,301 +2583,619 @@ func (m model) clampScrollOffset(offset int) int {
 	return offset
 }
 
-// getNavItems returns all navigable items in sidebar order
+// getNavItems returns all navigable items in sidebar order: teams, then
+// channels, then DMs. The section boundaries and a position index are built
+// alongside, so moving the cursor never walks or rebuilds the list.
 // Pike/Cox: cache to avoid repeated allocations
 func (m *model) getNavItems() []navItem {
 	if !m.navItemsDirty {
 		return m.navItemsCache
 	}
-	var items []navItem
+	items := make([]navItem, 0, len(m.teams)+len(m.channels))
+
+	// A collapsed section is a single item, its header, with index -1
+	addSection := func(itemType navItemType, indices []int) {
+		if m.collapsed[itemType] {
+			items = append(items, navItem{itemType: itemType, index: -1})
+			return
+		}
+		for _, i := range indices {
+			items = append(items, navItem{itemType: itemType, index: i})
+		}
+	}
 
 	// Always add teams
+	teams := make([]int, len(m.teams))
 	for i := range m.teams {
-		items = append(items, navItem{itemType: navTeam, index: i})
+		teams[i] = i
 	}
+	addSection(navTeam, teams)
+	m.navChannelStart = len(items)
 
 	// Add channels and DMs if team selected
 	if m.teamSelected {
-		// Add regular channels
-		for i, ch := range m.channels {
-			if ch.Type == comm.ChannelTypeDirectMessage || ch.Type == comm.ChannelTypeGroupMessage {
-				continue
-			}
-			items = append(items, navItem{itemType: navChannel, index: i})
-		}
-
-		// Add DMs
+		var channels, dms []int
 		for i, ch := range m.channels {
-			if ch.Type != comm.ChannelTypeDirectMessage && ch.Type != comm.ChannelTypeGroupMessage {
-				continue
+			if isDM(ch) {
+				dms = append(dms, i)
+			} else {
+				channels = append(channels, i)
 			}
-			items = append(items, navItem{itemType: navDM, index: i})
 		}
+		addSection(navChannel, m.filterChannels(channels))
+		m.navDMStart = len(items)
+		addSection(navDM, m.filterChannels(dms))
+	} else {
+		m.navDMStart = len(items)
 	}
 
+	m.navPos = make(map[navItem]int, len(items))
+	for i, item := range items {
+		m.navPos[item] = i
+	}
+	m.navNamesWidth = m.sidebarNamesWidth()
 	m.navItemsCache = items
 	m.navItemsDirty = false
 	return items
 }
 
+// sidebarNamesWidth returns the columns the widest sidebar entry needs,
+// with its marker and channel number but without unread badges, which
+// come and go. Entries the filter hides count too, so that the sidebar
+// does not change width while typing a filter.
+func (m *model) sidebarNamesWidth() int {
+	width := 0
+	for _, team := range m.teams {
+		name := team.DisplayName
+		if name == "" {
+			name = team.Name
+		}
+		width = max(width, lipgloss.Width(name))
+	}
+	if m.teamSelected {
+		number := len(fmt.Sprint(len(m.channels))) + 1 // "12:"
+		for _, ch := range m.channels {
+			w := lipgloss.Width(channelName(ch))
+			if !isDM(ch) {
+				w += number
+			}
+			width = max(width, w)
+		}
+	}
+	return marker.width() + 2 + width
+}
+
+// filterChannels returns the channel indices matching the sidebar filter,
+// best match first. Both the display name and the handle are searched.
+func (m *model) filterChannels(indices []int) []int {
+	if m.sidebarFilter == "" {
+		return indices
+	}
+	names := make([]string, len(indices))
+	for n, i := range indices {
+		names[n] = m.channels[i].DisplayName + " " + m.channels[i].Name
+	}
+	ranked := fuzzyRank(m.sidebarFilter, names)
+	for n, r := range ranked {
+		ranked[n] = indices[r]
+	}
+	return ranked
+}
+
+// setSidebarFilter changes the filter and, if the cursor's item was
+// filtered out, moves the cursor to the best match.
+func (m *model) setSidebarFilter(filter string) {
+	m.sidebarFilter = filter
+	m.navItemsDirty = true
+	items := m.getNavItems()
+	if _, ok := m.navPos[navItem{itemType: m.selectedType, index: m.selected}]; ok {
+		return
+	}
+	if m.navChannelStart < len(items) {
+		m.selected = items[m.navChannelStart].index
+		m.selectedType = items[m.navChannelStart].itemType
+		m.keepSidebarCursorVisible()
+	}
+}
+
+// handleFilterKeys edits the sidebar filter while its prompt is open.
+// Up/Down and Space still move through and pick from the matches.
+func (m model) handleFilterKeys(key string) (tea.Model, tea.Cmd, bool) {
+	if m.focus != focusSidebar || !m.filtering {
+		return m, nil, false
+	}
+	switch {
+	case keys.action(ctxGeneral, key) == actCancel:
+		m.filtering = false
+		m.setSidebarFilter("")
+	case keys.action(ctxPrompt, key) == actAccept:
+		m.filtering = false
+	case keys.action(ctxInput, key) == actDeleteBack:
+		runes := []rune(m.sidebarFilter)
+		if len(runes) > 0 {
+			m.setSidebarFilter(string(runes[:len(runes)-1]))
+		}
+	case len(key) == 1 && key[0] > printableCharMin && key[0] <= printableCharMax:
+		// Space is left to select, as channel names rarely contain one
+		m.setSidebarFilter(m.sidebarFilter + key)
+	default:
+		return m, nil, false
+	}
+	return m, nil, true
+}
+
+// toggleSection collapses the sidebar section the cursor is in, leaving
+// the cursor on its header, or expands it with the cursor on its first
+// item
+func (m *model) toggleSection() {
+	section := m.selectedType
+	if m.collapsed == nil {
+		m.collapsed = make(map[navItemType]bool)
+	}
+	m.collapsed[section] = !m.collapsed[section]
+	m.navItemsDirty = true
+	items := m.getNavItems()
+	start, end := 0, m.navChannelStart
+	switch section {
+	case navChannel:
+		start, end = m.navChannelStart, m.navDMStart
+	case navDM:
+		start, end = m.navDMStart, len(items)
+	}
+	if start < end {
+		m.selected = items[start].index
+	}
+	m.keepSidebarCursorVisible()
+}
+
 // getCurrentNavPosition returns the current position in the nav list
 func (m *model) getCurrentNavPosition() int {
-	items := m.getNavItems()
+	m.getNavItems()
 	// Find item matching both type and index
-	for i, item := range items {
-		if item.itemType == m.selectedType && item.index == m.selected {
-			return i
+	if pos, ok := m.navPos[navItem{itemType: m.selectedType, index: m.selected}]; ok {
+		return pos
+	}
+	// Default to first item
+	return 0
+}
+
+// isItemSelected checks if an item is the currently selected one
+func (m *model) isItemSelected(itemType navItemType, index int) bool {
+	return m.selectedType == itemType && m.selected == index
+}
+
+// navigateSidebar moves cursor up/down in sidebar with wrap-around
+func (m *model) navigateSidebar(delta int) {
+	items := m.getNavItems()
+	if len(items) == 0 {
+		return
+	}
+	currentPos := m.getCurrentNavPosition()
+	newPos := (currentPos + delta) % len(items)
+	if newPos < 0 {
+		newPos += len(items)
+	}
+	newItem := items[newPos]
+	m.selected = newItem.index
+	m.selectedType = newItem.itemType
+	m.keepSidebarCursorVisible()
+}
+
+// keepSidebarCursorVisible scrolls the channel or DM section so the
+// sidebar cursor is inside its displayed window.
+func (m *model) keepSidebarCursorVisible() {
+	pos := m.getCurrentNavPosition()
+	switch m.selectedType {
+	case navChannel:
+		m.sidebarScroll = scrollToShow(m.sidebarScroll, pos-m.navChannelStart, maxChannelsDisplay)
+	case navDM:
+		m.dmScroll = scrollToShow(m.dmScroll, pos-m.navDMStart, maxDMsDisplay)
+	}
+}
+
+// scrollToShow returns the window offset closest to scroll that shows
+// item i in a window of size items
+func scrollToShow(scroll, i, size int) int {
+	if i < scroll {
+		return i
+	}
+	if i >= scroll+size {
+		return i - size + 1
+	}
+	return scroll
+}
+
+// sectionWindow returns the [start, end) window of a sidebar section with
+// n items, showing at most size of them from scroll
+func sectionWindow(n, scroll, size int) (int, int) {
+	start := max(min(scroll, n-size), 0)
+	return start, min(start+size, n)
+}
+
+// nick returns username for display
+func (m *model) nick(userID string) string {
+	if userID == "" {
+		return "unknown"
+	}
+	if user, ok := m.users[userID]; ok {
+		if user.Username != "" {
+			return user.Username
+		}
+	} else if _, ok := m.userFetches[userID]; !ok {
+		// Fetched after this frame by fetchUsers; never from here, as
+		// nick is called while rendering
+		m.userFetches[userID] = false
+	}
+	// Fallback until the user arrives
+	if len(userID) > userIDTruncateLen {
+		return userID[:userIDTruncateLen]
+	}
+	return userID
+}
+
+// addUsers caches users that are not cached yet
+func (m *model) addUsers(users []comm.User) {
+	for i := range users {
+		u := users[i]
+		if _, ok := m.users[u.ID]; ok {
+			continue
 		}
+		m.users[u.ID] = &u
+		delete(m.userFetches, u.ID)
+		m.msgPaneDirty = true // may name a sender shown by ID
+	}
+}
+
+// fetchUsers starts fetching the users nick found missing. Each is asked
+// for once; a failed fetch is not retried, leaving the truncated ID.
+func (m *model) fetchUsers() tea.Cmd {
+	if m.platform == nil {
+		return nil
+	}
+	var cmds []tea.Cmd
+	for id, started := range m.userFetches {
+		if !started {
+			m.userFetches[id] = true
+			cmds = append(cmds, fetchUser(m.platform, id))
+		}
+	}
+	return tea.Batch(cmds...)
+}
+
+func fetchUser(platform platformAPI, userID string) tea.Cmd {
+	return func() tea.Msg {
+		user, err := platform.GetUser(userID)
+		return userFetchedMsg{id: userID, user: user, err: err}
+	}
+}
+
+// replyRoot returns the thread root ID of msg, "" for a root post, from
+// the cache for loaded messages
+func (m *model) replyRoot(msg comm.Message) string {
+	if rootID, ok := m.rootIDs[msg.ID]; ok {
+		return rootID
+	}
+	return threadRootID(msg)
+}
+
+// nickStyle returns the style for a sender's nick: mine in style.nick,
+// bold so my messages stand out, anyone else's in a palette color picked
+// by hashing their ID, so each person keeps the same color
+func (m model) nickStyle(userID string) lipgloss.Style {
+	if userID == m.myUserID || len(style.nicks) == 0 {
+		return style.nick
+	}
+	h := fnv.New32a()
+	h.Write([]byte(userID))
+	return style.nicks[h.Sum32()%uint32(len(style.nicks))]
+}
+
+func isThreadReply(msg comm.Message) bool {
+	// Thread replies have non-empty root_id in metadata
+	return threadRootID(msg) != ""
+}
+
+// postMetadata returns a field of the post metadata. Mattermost nests
+// reactions, files and embeds under "metadata"; both levels are checked.
+func postMetadata(msg comm.Message, key string) interface{} {
+	meta, ok := msg.Metadata.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	if v, ok := meta[key]; ok {
+		return v
+	}
+	if nested, ok := meta["metadata"].(map[string]interface{}); ok {
+		return nested[key]
 	}
-	// Default to first item
-	return 0
+	return nil
 }
 
-// isItemSelected checks if an item is the currently selected one
-func (m *model) isItemSelected(itemType navItemType, index int) bool {
-	return m.selectedType == itemType && m.selected == index
+// fileInfo describes a file attached to a message
+type fileInfo struct {
+	id       string
+	name     string
+	size     int64
+	mimeType string
 }
 
-// navigateSidebar moves cursor up/down in sidebar with wrap-around
-func (m *model) navigateSidebar(delta int) {
-	items := m.getNavItems()
-	if len(items) == 0 {
-		return
+// attachments returns the files attached to msg
+func attachments(msg comm.Message) []fileInfo {
+	list, _ := postMetadata(msg, "files").([]interface{})
+	var files []fileInfo
+	for _, item := range list {
+		f, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		info := fileInfo{}
+		info.id, _ = f["id"].(string)
+		info.name, _ = f["name"].(string)
+		info.mimeType, _ = f["mime_type"].(string)
+		if size, ok := f["size"].(float64); ok {
+			info.size = int64(size)
+		}
+		if info.name == "" {
+			info.name = info.id
+		}
+		files = append(files, info)
 	}
-	currentPos := m.getCurrentNavPosition()
-	newPos := (currentPos + delta) % len(items)
-	if newPos < 0 {
-		newPos += len(items)
+	return files
+}
+
+// formatSize renders a byte count as "1.2 MB"
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
 	}
-	newItem := items[newPos]
-	m.selected = newItem.index
-	m.selectedType = newItem.itemType
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGT"[exp])
 }
 
-// nick returns username for display
-func (m *model) nick(userID string) string {
-	if userID == "" {
-		return "unknown"
+// isEdited reports whether msg was edited after it was posted, and when.
+// Mattermost sets edit_at (milliseconds) on edits; update_at also moves for
+// other reasons, such as reactions, so it is only a fallback.
+func isEdited(msg comm.Message) (time.Time, bool) {
+	meta, ok := msg.Metadata.(map[string]interface{})
+	if !ok {
+		return time.Time{}, false
 	}
-	if user, ok := m.users[userID]; ok {
-		if user.Username != "" {
-			return user.Username
-		}
+	if editAt := metaTime(meta, "edit_at"); !editAt.IsZero() {
+		return editAt, true
 	}
-	// Fetch and cache
-	if m.platform != nil {
-		if user, err := m.platform.GetUser(userID); err == nil && user != nil {
-			m.users[userID] = user
-			if user.Username != "" {
-				return user.Username
-			}
-		}
+	if _, hasEditAt := meta["edit_at"]; hasEditAt {
+		return time.Time{}, false
 	}
-	// Fallback
-	if len(userID) > userIDTruncateLen {
-		return userID[:userIDTruncateLen]
+	// Allow for CreatedAt being stored with less precision than update_at
+	if updateAt := metaTime(meta, "update_at"); updateAt.After(msg.CreatedAt.Add(time.Second)) && len(reactions(msg)) == 0 {
+		return updateAt, true
 	}
-	return userID
+	return time.Time{}, false
 }
 
-func isThreadReply(msg comm.Message) bool {
-	// Thread replies have non-empty root_id in metadata
+// metaTime reads a millisecond timestamp from post metadata
+func metaTime(meta map[string]interface{}, key string) time.Time {
+	var ms int64
+	switch v := meta[key].(type) {
+	case float64:
+		ms = int64(v)
+	case int64:
+		ms = v
+	case int:
+		ms = int64(v)
+	}
+	if ms <= 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
+
+// threadRootID returns the ID of the thread root msg replies to, or ""
+func threadRootID(msg comm.Message) string {
 	if msg.Metadata == nil {
-		return false
+		return ""
 	}
 	meta, ok := msg.Metadata.(map[string]interface{})
 	if !ok {
-		return false
+		return ""
 	}
-	rootID, ok := meta["root_id"].(string)
-	return ok && rootID != ""
+	rootID, _ := meta["root_id"].(string)
+	return rootID
 }
 
 func (m model) isDMChannel() bool {
 	if len(m.channels) == 0 || m.current < 0 || m.current >= len(m.channels) {
 		return false
 	}
-	ch := m.channels[m.current]
+	return isDM(m.channels[m.current])
+}
+
+// isDM reports whether ch is a direct or group message channel
+func isDM(ch comm.Channel) bool {
 	return ch.Type == comm.ChannelTypeDirectMessage || ch.Type == comm.ChannelTypeGroupMessage
 }
 
+// channelName returns the name shown for a channel
+func channelName(ch comm.Channel) string {
+	if ch.DisplayName != "" {
+		return ch.DisplayName
+	}
+	return ch.Name
+}
+
 // Pike/Cox: extract rendering functions from View to reduce function size
-// renderSidebar renders the teams, channels, and DMs sidebar
+// renderSidebar renders the teams, channels, and DMs sidebar.
 func (m model) renderSidebar(sidebar int) string {
 	var b strings.Builder
+	for _, row := range m.sidebarRows(sidebar) {
+		b.WriteString(row.text + "\n")
+	}
+	return b.String()
+}
 
-	// Teams section
-	teamHeader := "=Teams="
-	if m.focus == focusSidebar {
-		teamHeader = "[Teams]"
+// sidebarRow is one line of the sidebar and the nav item on it, if any.
+// Mouse clicks are mapped to items through the same rows that are drawn.
+type sidebarRow struct {
+	text string
+	item *navItem
+}
+
+// sidebarRows lays out the sidebar, one row per screen line.
+// Only the visible window of each section is walked, so the cost of a frame
+// does not grow with the number of channels the user belongs to.
+func (m model) sidebarRows(sidebar int) []sidebarRow {
+	var rows []sidebarRow
+	add := func(text string, item *navItem) {
+		rows = append(rows, sidebarRow{text, item})
 	}
-	b.WriteString(teamHeader + "\n")
-	for i, team := range m.teams {
-		name := team.DisplayName
-		if name == "" {
-			name = team.Name
+	items := m.getNavItems()
+
+	// header adds a section header. A collapsed section is its header
+	// alone, marked [+], standing in for the section's items.
+	header := func(name string, section []navItem) bool {
+		text := "=" + name + "="
+		if m.focus == focusSidebar {
+			text = "[" + name + "]"
+		}
+		if len(section) == 0 || section[0].index >= 0 {
+			add(text, nil)
+			return false
 		}
-		if len(name) > sidebar-3 {
-			name = name[:sidebar-4] + "~"
+		text += " [+]"
+		if m.isItemSelected(section[0].itemType, -1) {
+			text = style.selected.Render(text)
 		}
-		// Marker: * for cursor, > for active team
-		marker := " "
-		baseText := fmt.Sprintf("%s%s", marker, name)
+		add(text, &section[0])
+		return true
+	}
 
-		// Show marker based on state
-		if m.teamSelected && i == m.currentTeam {
-			// This is the active team
-			marker = ">"
-			baseText = fmt.Sprintf("%s%s", marker, name)
-			if len(baseText) < sidebar {
-				baseText += strings.Repeat(" ", sidebar-len(baseText))
-			}
-			b.WriteString(style.current.Render(baseText) + "\n")
-		} else if m.isItemSelected(navTeam, i) {
-			// Cursor is on this team
-			marker = "*"
-			baseText = fmt.Sprintf("%s%s", marker, name)
-			if len(baseText) < sidebar {
-				baseText += strings.Repeat(" ", sidebar-len(baseText))
-			}
-			b.WriteString(style.selected.Render(baseText) + "\n")
-		} else {
-			if len(baseText) < sidebar {
-				baseText += strings.Repeat(" ", sidebar-len(baseText))
+	// Teams section
+	teams := items[:m.navChannelStart]
+	if !header("Teams", teams) {
+		for i, item := range teams {
+			team := m.teams[item.index]
+			name := team.DisplayName
+			if name == "" {
+				name = team.Name
 			}
-			b.WriteString(baseText + "\n")
+			active := m.teamSelected && item.index == m.currentTeam
+			add(sidebarLine(name, 0, active, m.isItemSelected(navTeam, item.index), sidebar), &teams[i])
 		}
 	}
-	b.WriteString("\n")
+	add("", nil)
 
 	// Channels section
-	header := "=Channels="
-	if m.focus == focusSidebar {
-		header = "[Channels]"
-	}
-	b.WriteString(header + "\n")
-
-	if m.teamSelected {
-		chCount := 0
-		for i, ch := range m.channels {
-			if ch.Type == comm.ChannelTypeDirectMessage || ch.Type == comm.ChannelTypeGroupMessage {
-				continue
-			}
-			name := ch.DisplayName
-			if name == "" {
-				name = ch.Name
-			}
-			if len(name) > sidebar-3 {
-				name = name[:sidebar-4] + "~"
-			}
-			// Marker: * for cursor, > for current active channel
-			marker := " "
-			baseText := fmt.Sprintf("%s%d:%s", marker, chCount+1, name)
-			if i == m.current {
-				marker = ">"
-				baseText = fmt.Sprintf("%s%d:%s", marker, chCount+1, name)
-				if len(baseText) < sidebar {
-					baseText += strings.Repeat(" ", sidebar-len(baseText))
-				}
-				b.WriteString(style.current.Render(baseText) + "\n")
-			} else if m.isItemSelected(navChannel, i) {
-				marker = "*"
-				baseText = fmt.Sprintf("%s%d:%s", marker, chCount+1, name)
-				if len(baseText) < sidebar {
-					baseText += strings.Repeat(" ", sidebar-len(baseText))
-				}
-				b.WriteString(style.selected.Render(baseText) + "\n")
-			} else {
-				if len(baseText) < sidebar {
-					baseText += strings.Repeat(" ", sidebar-len(baseText))
-				}
-				b.WriteString(baseText + "\n")
-			}
-			chCount++
-			if chCount >= maxChannelsDisplay {
-				break
+	channels := items[m.navChannelStart:m.navDMStart]
+	if !header("Channels", channels) {
+		if m.filtering || m.sidebarFilter != "" {
+			prompt := "/" + m.sidebarFilter
+			if m.filtering {
+				prompt += "█"
 			}
+			add(fitWidth(prompt, sidebar), nil)
 		}
-	}
 
-	// DMs section
-	dmHeader := "\n=DMs="
-	if m.focus == focusSidebar {
-		dmHeader = "\n[DMs]"
+		start, end := sectionWindow(len(channels), m.sidebarScroll, maxChannelsDisplay)
+		if start > 0 {
+			add(moreAbove(start, sidebar), nil)
+		}
+		for n := start; n < end; n++ {
+			ch := m.channels[channels[n].index]
+			name := fmt.Sprintf("%d:%s", n+1, channelName(ch))
+			add(sidebarLine(name, m.unread[ch.ID], channels[n].index == m.current, m.isItemSelected(navChannel, channels[n].index), sidebar), &channels[n])
+		}
+		if len(channels) > end {
+			add(moreBelow(len(channels)-end, sidebar), nil)
+		}
 	}
-	b.WriteString(dmHeader + "\n")
 
-	if m.teamSelected {
-		dmCount := 0
-		for i, ch := range m.channels {
-			if ch.Type != comm.ChannelTypeDirectMessage && ch.Type != comm.ChannelTypeGroupMessage {
-				continue
-			}
-			name := ch.DisplayName
-			if len(name) > sidebar-3 {
-				name = name[:sidebar-4] + "~"
-			}
-			// Marker: * for cursor, > for current active DM
-			marker := " "
-			baseText := fmt.Sprintf("%s%s", marker, name)
-			if i == m.current {
-				marker = ">"
-				baseText = fmt.Sprintf("%s%s", marker, name)
-				if len(baseText) < sidebar {
-					baseText += strings.Repeat(" ", sidebar-len(baseText))
-				}
-				b.WriteString(style.current.Render(baseText) + "\n")
-			} else if m.isItemSelected(navDM, i) {
-				marker = "*"
-				baseText = fmt.Sprintf("%s%s", marker, name)
-				if len(baseText) < sidebar {
-					baseText += strings.Repeat(" ", sidebar-len(baseText))
-				}
-				b.WriteString(style.selected.Render(baseText) + "\n")
-			} else {
-				if len(baseText) < sidebar {
-					baseText += strings.Repeat(" ", sidebar-len(baseText))
-				}
-				b.WriteString(baseText + "\n")
-			}
-			dmCount++
-			if dmCount >= maxDMsDisplay {
-				break
-			}
+	// DMs section
+	add("", nil)
+	dms := items[m.navDMStart:]
+	if !header("DMs", dms) {
+		start, end := sectionWindow(len(dms), m.dmScroll, maxDMsDisplay)
+		if start > 0 {
+			add(moreAbove(start, sidebar), nil)
+		}
+		for n := start; n < end; n++ {
+			ch := m.channels[dms[n].index]
+			add(sidebarLine(channelName(ch), m.unread[ch.ID], dms[n].index == m.current, m.isItemSelected(navDM, dms[n].index), sidebar), &dms[n])
+		}
+		if len(dms) > end {
+			add(moreBelow(len(dms)-end, sidebar), nil)
 		}
 	}
+	return rows
+}
 
-	return b.String()
+// moreAbove returns the "↑ N more" line for items scrolled off the top of
+// a sidebar section. moreBelow is its opposite.
+func moreAbove(n, sidebar int) string {
+	return style.time.Render(fitWidth(fmt.Sprintf(" ↑ %d more", n), sidebar))
+}
+
+func moreBelow(n, sidebar int) string {
+	return style.time.Render(fitWidth(fmt.Sprintf(" ↓ %d more", n), sidebar))
+}
+
+// sidebarLine formats one sidebar entry padded to the sidebar width.
+// Marker: active marker for the active item, cursor marker for the cursor.
+// A non-zero unread count is shown as a trailing (N) badge.
+func sidebarLine(name string, unread int, active, selected bool, sidebar int) string {
+	markerWidth := marker.width()
+	badge := ""
+	if unread > 0 {
+		badge = fmt.Sprintf(" (%d)", unread)
+	}
+	if avail := sidebar - markerWidth - 2 - len(badge); lipgloss.Width(name) > avail {
+		name = fitWidth(name, avail-1) + "~"
+	}
+	prefix := ""
+	if active {
+		prefix = marker.active
+	} else if selected {
+		prefix = marker.cursor
+	}
+	prefix += strings.Repeat(" ", markerWidth-lipgloss.Width(prefix))
+	text := prefix + name
+	pad := ""
+	if w := lipgloss.Width(text) + len(badge); w < sidebar {
+		pad = strings.Repeat(" ", sidebar-w)
+	}
+	if badge != "" {
+		badge = style.activity.Render(badge)
+	}
+	switch {
+	case active:
+		return style.current.Render(text) + badge + pad
+	case selected:
+		return style.selected.Render(text) + badge + pad
+	}
+	return text + badge + pad
 }
 
 // renderMessages renders the message area with proper scrolling
 func (m model) renderMessages(mainWidth, msgHeight int) string {
+	if m.loadingTeam != "" {
+		return paneNote("Loading channels…", mainWidth, msgHeight)
+	}
+	if m.teamSelected && len(m.channels) == 0 {
+		return paneNote("No channels in this team. Pick another team in the sidebar.", mainWidth, msgHeight)
+	}
+
 	var b strings.Builder
 
 	displayMsgs := m.getDisplayMessages()
 	totalMsgs := len(displayMsgs)
-	end := totalMsgs - m.scrollOffset
-	if end > totalMsgs {
-		end = totalMsgs
-	}
-	if end < 0 {
-		end = 0
-	}
+	end := max(0, min(totalMsgs-m.scrollOffset, totalMsgs))
 
 	// Work backward from 'end', counting screen lines used
-	linesUsed := 0
-	start := end
-	for start > 0 && linesUsed < msgHeight {
-		msgIdx := start - 1
-		msg := displayMsgs[msgIdx]
-		msgLines := len(strings.Split(msg.Text, "\n"))
-		if linesUsed+msgLines > msgHeight && linesUsed > 0 {
-			// This message won't fit, stop here
-			break
-		}
-		linesUsed += msgLines
-		start--
-	}
+	start, linesUsed := m.visibleRange(displayMsgs, end, msgHeight)
 
 	// Fill empty lines at top (for bottom alignment)
 	for i := 0; i < msgHeight-linesUsed; i++ {
@@ -1315,84 +3204,279 @@ func (m model) renderMessages(mainWidth, msgHeight int) string {
 
 	// Render messages at bottom with multi-line support
 	for i := start; i < end; i++ {
-		msg := displayMsgs[i]
-		t := msg.CreatedAt.Format("15:04")
-		nick := m.nick(msg.SenderID)
-		text := msg.Text
-
-		// Handle multi-line messages
-		lines := strings.Split(text, "\n")
-		isHighlighted := i == m.messageCursor
-
-		for lineIdx, textLine := range lines {
-			var line string
-			if lineIdx == 0 {
-				// First line: show time and nick
-				timeStr := t
-				nickStr := fmt.Sprintf("<%s>", nick)
-				prefixWidth := len(timeStr) + 1 + len(nickStr) + 1 // "HH:MM <nick> "
-				availableWidth := mainWidth - prefixWidth
-				if availableWidth < 0 {
-					availableWidth = 0
-				}
+		if m.startsHistory(displayMsgs[i]) {
+			b.WriteString(historyStartMarker(m.channels[m.current], mainWidth))
+			b.WriteString("\n")
+		}
+		if i == start || dateBreak(displayMsgs, i) {
+			b.WriteString(dateSeparator(displayMsgs[i].CreatedAt, mainWidth))
+			b.WriteString("\n")
+		}
+		if displayMsgs[i].ID == m.newSince {
+			b.WriteString(newMessagesDivider(mainWidth))
+			b.WriteString("\n")
+		}
+		for _, line := range m.renderMessage(displayMsgs[i], i == m.messageCursor, mainWidth) {
+			b.WriteString(line)
+			b.WriteString("\n")
+		}
+		for _, line := range m.renderPreviews(displayMsgs[i], mainWidth) {
+			b.WriteString(line)
+			b.WriteString("\n")
+		}
+	}
 
-				// Truncate text if needed, add ellipsis
-				if len(textLine) > availableWidth {
-					if availableWidth > minTruncateWidth {
-						textLine = textLine[:availableWidth-ellipsisLen] + "..."
-					} else if availableWidth > 0 {
-						textLine = textLine[:availableWidth]
-					} else {
-						textLine = ""
-					}
-				}
+	return b.String()
+}
 
-				if isHighlighted {
-					// Use highlighted style for all parts
-					line = fmt.Sprintf("%s %s %s",
-						style.highlighted.Render(timeStr),
-						style.highlighted.Render(nickStr),
-						style.highlighted.Render(textLine))
-				} else {
-					// Use normal styles
-					line = fmt.Sprintf("%s %s %s",
-						style.time.Render(timeStr),
-						style.nick.Render(nickStr),
-						textLine)
-				}
-			} else {
-				// Continuation lines: indent
-				nickWidth := len(nick) + nickPrefixLen + nickSuffixLen
-				indent := strings.Repeat(" ", timeWidth+1+nickWidth)
-				availableWidth := mainWidth - len(indent)
-				if availableWidth < 0 {
-					availableWidth = 0
-				}
+// paneNote fills the message pane with a note for a team with no channels
+// or none yet, at the bottom where messages would start
+func paneNote(text string, mainWidth, msgHeight int) string {
+	note := style.time.Render(fitWidth(text, mainWidth))
+	return strings.Repeat("\n", max(msgHeight-1, 0)) + note + "\n"
+}
 
-				// Truncate text if needed, add ellipsis
-				if len(textLine) > availableWidth {
-					if availableWidth > minTruncateWidth {
-						textLine = textLine[:availableWidth-ellipsisLen] + "..."
-					} else if availableWidth > 0 {
-						textLine = textLine[:availableWidth]
-					} else {
-						textLine = ""
-					}
-				}
+// cacheMessagePane re-renders the message pane only if something it shows
+// changed, so that cursor blinks and typing reuse the last rendering
+// instead of laying out the whole transcript again
+func (m *model) cacheMessagePane() {
+	if !m.connected {
+		return
+	}
+	key := msgPaneKey{m.scrollOffset, m.messageCursor, m.layoutMainWidth(), m.msgHeight()}
+	if !m.msgPaneDirty && key == m.msgPaneKey {
+		return
+	}
+	m.msgPane = m.renderMessages(key.width, key.height)
+	m.msgPaneKey = key
+	m.msgPaneDirty = false
+}
 
-				if isHighlighted {
-					line = style.highlighted.Render(indent + textLine)
-				} else {
-					line = indent + textLine
+// messagePane returns the message pane, from the cache when it is current
+func (m model) messagePane(mainWidth, msgHeight int) string {
+	if !m.msgPaneDirty && m.msgPaneKey == (msgPaneKey{m.scrollOffset, m.messageCursor, mainWidth, msgHeight}) {
+		return m.msgPane
+	}
+	return m.renderMessages(mainWidth, msgHeight)
+}
+
+// renderMessage renders one message as screen lines: the first line carries
+// time and nick, continuation lines are indented under the text.
+// It must produce exactly messageLineCount(msg) lines.
+func (m model) renderMessage(msg comm.Message, isHighlighted bool, mainWidth int) []string {
+	if isSystemMessage(msg) {
+		return m.renderSystemMessage(msg, isHighlighted, mainWidth)
+	}
+	t := padRight(msg.CreatedAt.Format(m.timeFormat), m.timeWidth)
+	nick := m.nick(msg.SenderID)
+
+	// Handle multi-line messages; inline Markdown styles may span lines
+	textLines := parseBody(msg.Text)
+	for i := range textLines {
+		textLines[i].spans = markLinks(textLines[i].spans, m.urlWidth)
+	}
+
+	// Inline thread replies are indented after the timestamp
+	replyIndent := ""
+	if m.showReplies && m.replyRoot(msg) != "" {
+		replyIndent = strings.Repeat(" ", replyIndentWidth)
+	}
+
+	mentioned := mentionsMe(msg.Text, m.myUsername)
+
+	// Continuation lines line up under the text of the first line
+	nickWidth := len(replyIndent) + lipgloss.Width(nick) + nickPrefixLen + nickSuffixLen
+	textIndent := m.timeWidth + 1 + nickWidth
+
+	// Dim markers after the last line of text, e.g. "(edited)". They only
+	// use space the text leaves free and never cause it to be truncated.
+	suffix := ""
+	if editedAt, ok := isEdited(msg); ok {
+		suffix += " (edited " + editedAt.Format(m.timeFormat) + ")"
+	} else if m.edited[msg.ID] {
+		suffix += " (edited)"
+	}
+	if i := m.outboxIndex(msg.ID); i >= 0 {
+		if m.outbox[i].failed {
+			suffix += " ✗ not sent (" + keys.describe(actReply) + " to resend)"
+		} else {
+			suffix += " (sending…)"
+		}
+	}
+	if n := m.threadReplyCount(msg.ID); n > 0 && !m.showReplies {
+		if n == 1 {
+			suffix += " [1 reply]"
+		} else {
+			suffix += fmt.Sprintf(" [%d replies]", n)
+		}
+	}
+
+	lines := make([]string, 0, len(textLines))
+	for lineIdx, body := range textLines {
+		lineSpans := body.spans
+		lineSuffix := ""
+		if lineIdx == len(textLines)-1 {
+			lineSuffix = suffix
+		}
+
+		var line string
+		if lineIdx == 0 {
+			// First line: show time and nick
+			timeStr := t
+			nickStr := replyIndent + fmt.Sprintf("<%s>", nick)
+			prefixWidth := lipgloss.Width(timeStr) + 1 + lipgloss.Width(nickStr) + 1 // "HH:MM <nick> "
+			lineSpans = markMatches(markMentions(fitBody(body, mainWidth-prefixWidth), m.myUsername), m.searchQuery)
+			lineSuffix = fitWidth(lineSuffix, mainWidth-prefixWidth-spansWidth(lineSpans))
+
+			// Gutter between time and nick flags messages mentioning me
+			gutter := " "
+			if mentioned {
+				gutter = style.activity.Render(mentionGutter)
+			}
+
+			if isHighlighted {
+				// Use highlighted style for all parts; my own nick stays
+				// bold so the selection does not hide whose it is
+				if mentioned {
+					gutter = style.highlighted.Render(mentionGutter)
 				}
+				nickHL := style.highlighted
+				if m.myUserID != "" && msg.SenderID == m.myUserID {
+					nickHL = nickHL.Bold(true)
+				}
+				line = fmt.Sprintf("%s%s%s %s",
+					style.highlighted.Render(timeStr),
+					gutter,
+					nickHL.Render(nickStr),
+					renderSpans(lineSpans, style.highlighted)+style.highlighted.Render(lineSuffix))
+			} else {
+				// Use normal styles
+				line = fmt.Sprintf("%s%s%s %s",
+					style.time.Render(timeStr),
+					gutter,
+					m.nickStyle(msg.SenderID).Render(nickStr),
+					renderSpans(lineSpans, lipgloss.NewStyle())+style.time.Render(lineSuffix))
 			}
+		} else {
+			// Continuation lines: indent
+			indent := strings.Repeat(" ", textIndent)
+			lineSpans = markMatches(markMentions(fitBody(body, mainWidth-textIndent), m.myUsername), m.searchQuery)
+			lineSuffix = fitWidth(lineSuffix, mainWidth-textIndent-spansWidth(lineSpans))
 
-			b.WriteString(line)
-			b.WriteString("\n")
+			if isHighlighted {
+				line = style.highlighted.Render(indent) + renderSpans(lineSpans, style.highlighted) + style.highlighted.Render(lineSuffix)
+			} else {
+				line = indent + renderSpans(lineSpans, lipgloss.NewStyle()) + style.time.Render(lineSuffix)
+			}
 		}
+		lines = append(lines, line)
 	}
 
-	return b.String()
+	// Attached files, one dim line each
+	for _, f := range attachments(msg) {
+		line := fitWidth(fmt.Sprintf("%s📎 %s (%s)", strings.Repeat(" ", textIndent), f.name, formatSize(f.size)), mainWidth)
+		if isHighlighted {
+			lines = append(lines, style.highlighted.Render(line))
+		} else {
+			lines = append(lines, style.time.Render(line))
+		}
+	}
+
+	// Reactions go on their own dim line under the text
+	if rs := reactions(msg); len(rs) > 0 {
+		line := fitWidth(strings.Repeat(" ", textIndent)+formatReactions(rs), mainWidth)
+		if isHighlighted {
+			lines = append(lines, style.highlighted.Render(line))
+		} else {
+			lines = append(lines, style.time.Render(line))
+		}
+	}
+	return lines
+}
+
+// fitBody fits a body line into width columns. Code lines are indented
+// as a block and cut with a marker; prose is cut with an ellipsis.
+func fitBody(body bodyLine, width int) []span {
+	if !body.code {
+		return fitSpans(body.spans, width)
+	}
+	text := ""
+	if len(body.spans) > 0 {
+		text = body.spans[0].text
+	}
+	indent := min(codeIndentWidth, max(width, 0))
+	return []span{
+		{text: strings.Repeat(" ", indent)},
+		{text: fitCode(text, width-indent), code: true},
+	}
+}
+
+// renderStatus renders the irssi-style status bar above the input line:
+// connection state, clock, current channel, how much of its history is
+// loaded, and the latest notice.
+func (m model) renderStatus(mainWidth int, channel string) string {
+	st := style.status
+	if m.statusFlash {
+		st = st.Reverse(true)
+	}
+	// The dot keeps its color, so it is rendered apart from the rest
+	dot := ""
+	if m.connected && mainWidth > connDotWidth {
+		dot = st.Foreground(m.connColor()).Render(" ●")
+		mainWidth -= connDotWidth
+	}
+
+	parts := []string{"[" + time.Now().Format(m.timeFormat) + "]"}
+	if channel != "" {
+		parts = append(parts, "["+channel+"]")
+		loaded := len(m.messages)
+		if m.totalMessages > 0 {
+			parts = append(parts, fmt.Sprintf("[loaded %d of ~%d]", loaded, m.totalMessages))
+		} else {
+			parts = append(parts, fmt.Sprintf("[loaded %d]", loaded))
+		}
+	}
+	if m.authorFilter != "" {
+		parts = append(parts, "[only <"+m.authorName+">, Esc for all]")
+	}
+	if pinned := m.pinnedCount(); pinned != "" {
+		parts = append(parts, "["+pinned+"]")
+	}
+	if typing := m.typingIndicator(); typing != "" {
+		parts = append(parts, "["+typing+"]")
+	}
+	if m.notice != "" {
+		parts = append(parts, "["+m.notice+"]")
+	}
+	if m.connected {
+		parts = append(parts, "["+m.lastEventStatus()+"]")
+	}
+	line := strings.Join(parts, " ")
+	if dot != "" {
+		line = " " + line
+	}
+	line = fitWidth(line, mainWidth)
+	return dot + st.Width(mainWidth).Render(line)
+}
+
+// typingIndicator returns "alice, bob are typing…" for the current
+// channel, or "" if nobody is
+func (m model) typingIndicator() string {
+	if len(m.typing) == 0 {
+		return ""
+	}
+	var nicks []string
+	for userID := range m.typing {
+		nicks = append(nicks, m.nick(userID))
+	}
+	sort.Strings(nicks)
+	if len(nicks) > maxTypingShown {
+		nicks = append(nicks[:maxTypingShown], fmt.Sprintf("%d more", len(nicks)-maxTypingShown))
+	}
+	if len(m.typing) == 1 {
+		return nicks[0] + " is typing…"
+	}
+	return strings.Join(nicks, ", ") + " are typing…"
 }
 
 // renderInput renders the input line with cursor
@@ -1401,9 +3485,9 @@ func (m model) renderInput(mainWidth int, channel string) string {
 	runes := []rune(displayInput)
 	var inputWithCursor string
 	cursorChar := " "
-	if m.focus == focusMain && m.cursorVisible {
+	if m.mainFocused() && m.cursorVisible {
 		cursorChar = "█"
-	} else if m.focus == focusMain {
+	} else if m.mainFocused() {
 		cursorChar = " "
 	} else {
 		cursorChar = "█"
@@ -1414,10 +3498,17 @@ func (m model) renderInput(mainWidth int, channel string) string {
 		inputWithCursor = string(runes[:m.cursorPos]) + cursorChar + string(runes[m.cursorPos:])
 	}
 	inputLine := fmt.Sprintf("[%s] %s", channel, inputWithCursor)
-	if len(inputLine) > mainWidth {
-		inputLine = inputLine[:mainWidth]
+	if m.searching {
+		inputLine = fmt.Sprintf("[%s] search: %s%s", channel, m.searchQuery, cursorChar)
+	} else if m.authorPrompt {
+		inputLine = fmt.Sprintf("[%s] only from: %s%s", channel, m.authorInput, cursorChar)
+	} else if m.editingMessageID != "" {
+		inputLine = fmt.Sprintf("[%s] (editing) %s", channel, inputWithCursor)
+	}
+	if m.flash != "" && time.Now().Before(m.flashUntil) {
+		inputLine += " (" + m.flash + ")"
 	}
-	return style.input.Render(inputLine)
+	return style.input.Render(fitWidth(inputLine, mainWidth))
 }
 
 // combinePanes combines left sidebar and right message area
@@ -1472,6 +3563,32 @@ func (m model) combinePanes(leftStr, rightStr string, sidebar, mainWidth, height
 	return b.String()
 }
 
+// layoutSidebarWidth returns the sidebar width for the current terminal:
+// the user's chosen width if any, or else wide enough for the longest
+// name listed, up to maxSidebarWidth and a third of the terminal. Either
+// way it leaves room for the message area.
+func (m model) layoutSidebarWidth() int {
+	width := m.width
+	if width == 0 {
+		width = defaultWidth
+	}
+	sidebar := min(min(m.navNamesWidth, maxSidebarWidth), width/sidebarWidthDiv)
+	if m.sidebarWidth > 0 {
+		sidebar = m.sidebarWidth
+	}
+	sidebar = min(sidebar, width-minMainWidth-1)
+	return max(sidebar, minSidebarWidth)
+}
+
+// layoutMainWidth returns the width of the message area right of the sidebar
+func (m model) layoutMainWidth() int {
+	width := m.width
+	if width == 0 {
+		width = defaultWidth
+	}
+	return max(width-m.layoutSidebarWidth()-1, minMainWidth) // -1 for separator
+}
+
 func (m model) View() string {
 	// Pike/Cox: simplified View function using extracted rendering methods
 	if !m.connected {
@@ -1492,76 +3609,113 @@ func (m model) View() string {
 	}
 
 	// Layout: sidebar | messages
-	sidebar := sidebarWidth
-	if width < minWidthForFullSide {
-		sidebar = sidebarWidthSmall
-	}
-	mainWidth := width - sidebar - 1 // -1 for separator
-	if mainWidth < minMainWidth {
-		mainWidth = minMainWidth
-	}
+	sidebar := m.layoutSidebarWidth()
+	mainWidth := m.layoutMainWidth()
 
 	// Get channel name for input line
 	channel := ""
 	if len(m.channels) > 0 && m.current >= 0 && m.current < len(m.channels) {
-		ch := m.channels[m.current]
-		name := ch.DisplayName
-		if name == "" {
-			name = ch.Name
+		channel = channelName(m.channels[m.current])
+		if m.threadRootID != "" {
+			channel += "/thread"
 		}
-		channel = name
 	}
 
 	// Render components
 	leftPane := m.renderSidebar(sidebar)
-	messagesPane := m.renderMessages(mainWidth, m.msgHeight())
+	messagesPane := m.messagePane(mainWidth, m.msgHeight())
+	if m.showingResults {
+		messagesPane = m.renderResults(mainWidth, m.msgHeight())
+	}
+	statusLine := m.renderStatus(mainWidth, channel)
+	if m.showingError() {
+		statusLine = m.renderError(mainWidth) + "\n" + statusLine
+	}
 	inputLine := m.renderInput(mainWidth, channel)
 
-	// Combine messages and input into right pane
-	rightPane := messagesPane + inputLine
+	if m.loadingOlder {
+		messagesPane = m.renderLoading(mainWidth) + "\n" + messagesPane
+	}
+	if m.showingPin() {
+		messagesPane = m.renderPinned(mainWidth) + "\n" + messagesPane
+	}
+
+	// Combine topic, messages, error line, status bar and input into right pane
+	rightPane := m.renderTopic(mainWidth) + "\n" + messagesPane + statusLine + "\n" + inputLine
 
 	// Combine left and right panes
-	return m.combinePanes(leftPane, rightPane, sidebar, mainWidth, height)
+	view := m.combinePanes(leftPane, rightPane, sidebar, mainWidth, height)
+	if popup := m.renderSuggestions(mainWidth); popup != "" {
+		// Just above the status bar, left-aligned with the messages
+		view = overlayAt(view, popup, sidebar+1, height-statusHeight-1-lipgloss.Height(popup))
+	}
+	if m.picking {
+		// Like the completion popup
+		popup := m.renderPicker(mainWidth)
+		view = overlayAt(view, popup, sidebar+1, height-statusHeight-1-lipgloss.Height(popup))
+	}
+	if m.showHelp {
+		view = overlay(view, m.renderHelp(), width, height)
+	}
+	if m.statusFlash {
+		// The bell goes out with the frame that starts the flash, through
+		// the renderer rather than around it. Redraws skip unchanged lines,
+		// so it rings once however often the flashing frame is drawn.
+		view = "\a" + view
+	}
+	return view
 }
 
 func main() {
-	// Parse CLI flags - NO environment variable fallbacks
-	host := flag.String("host", "", "Mattermost server host (e.g., chat.example.com)")
-	token := flag.String("token", "", "Personal Access Token")
-	user := flag.String("user", "", "Username or email for login")
-	pass := flag.String("pass", "", "Password for login")
-	teamID := flag.String("teamid", "", "Team ID (optional)")
+	// Non-interactive modes exit here
+	if runSubcommand(os.Args[1:]) {
+		return
+	}
+
+	// Parse CLI flags; they win over MATTERMOST_* variables and config.toml
+	conn := addConnFlags(flag.CommandLine)
 	debug := flag.Bool("debug", false, "Enable debug logging to termunicator_debug.log")
+	showVersion := flag.Bool("version", false, "Print the termunicator and libcommunicator versions and exit")
+	sidebar := flag.Int("sidebar-width", 0, "Sidebar width (overrides the saved preference)")
+	bell := flag.Bool("bell", false, "Ring the terminal bell and flash the status bar when mentioned in another channel")
+	images := flag.Bool("images", false, "Show image attachments inline with Kitty or iTerm2 graphics (not Sixel), chosen from $TERM, $TERM_PROGRAM and $KITTY_WINDOW_ID rather than asking the terminal (downloads every image shown)")
+	noConfirm := flag.Bool("no-confirm", false, "Quit on the first Ctrl+C even with a message typed or unsent")
+	urlWidth := flag.Int("url-width", -1, "Shorten URLs wider than this many columns to host/…/last-part (0 = never; default 40)")
+	downloadDir := flag.String("download-dir", "", "Directory attachments are saved to (default ~/Downloads)")
+	timeFormat := flag.String("time-format", "", "Timestamp layout in Go time format, e.g. \"3:04 PM\" or \"15:04:05\" (default \"15:04\")")
+	flag.StringVar(&marker.cursor, "cursor-marker", marker.cursor, "Sidebar marker for the cursor")
+	flag.StringVar(&marker.active, "active-marker", marker.active, "Sidebar marker for the active team/channel")
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "termunicator - irssi-style TUI for Mattermost\n\n")
-		fmt.Fprintf(os.Stderr, "Usage: termunicator -host HOST [-token TOKEN | -user USER -pass PASS]\n\n")
-		fmt.Fprintf(os.Stderr, "Options:\n")
+		fmt.Fprintf(os.Stderr, "Usage: termunicator -host HOST [-token TOKEN | -token-cmd CMD | -user USER -pass PASS]\n")
+		fmt.Fprintf(os.Stderr, "       termunicator COMMAND [flags] (COMMAND -h for its flags)\n\n")
+		fmt.Fprintf(os.Stderr, "Settings may also come from MATTERMOST_HOST, MATTERMOST_TOKEN,\n")
+		fmt.Fprintf(os.Stderr, "MATTERMOST_TOKEN_COMMAND, MATTERMOST_LOGIN_ID, MATTERMOST_PASSWORD,\n")
+		fmt.Fprintf(os.Stderr, "MATTERMOST_TEAM_ID or config.toml;\n")
+		fmt.Fprintf(os.Stderr, "flags win over the environment, which wins over the file.\n\n")
+		fmt.Fprintf(os.Stderr, "Commands:\n")
+		printSubcommands(os.Stderr)
+		fmt.Fprintf(os.Stderr, "\nOptions:\n")
 		flag.PrintDefaults()
 		fmt.Fprintf(os.Stderr, "\nKeys:\n")
-		fmt.Fprintf(os.Stderr, "  Ctrl+B         Switch focus (sidebar/main)\n")
-		fmt.Fprintf(os.Stderr, "\n  Sidebar focus:\n")
-		fmt.Fprintf(os.Stderr, "    Up/Down      Select channel (* marker)\n")
-		fmt.Fprintf(os.Stderr, "    Space        Switch to selected (> marker)\n")
-		fmt.Fprintf(os.Stderr, "\n  Main focus:\n")
-		fmt.Fprintf(os.Stderr, "    Up/Down      Scroll by line (auto-fetch older)\n")
-		fmt.Fprintf(os.Stderr, "    PgUp/PgDown  Scroll by page (auto-fetch older)\n")
-		fmt.Fprintf(os.Stderr, "    Enter        Send message\n")
-		fmt.Fprintf(os.Stderr, "    Ctrl+Enter   New line in message\n")
-		fmt.Fprintf(os.Stderr, "    Backspace    Delete character\n")
-		fmt.Fprintf(os.Stderr, "    (any key)    Type message\n")
-		fmt.Fprintf(os.Stderr, "\n  Ctrl+C         Quit\n")
+		printKeys(os.Stderr)
 	}
 
 	flag.Parse()
 
+	if *showVersion {
+		fmt.Println(versionString())
+		return
+	}
+
 	// Setup debug logging if requested
 	if *debug {
 		logFile, err := os.OpenFile("termunicator_debug.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 		if err == nil {
 			log.SetOutput(logFile)
 			defer logFile.Close()
-			log.Printf("=== termunicator started (debug mode) ===")
+			log.Printf("=== %s started (debug mode) ===", versionString())
 		} else {
 			fmt.Fprintf(os.Stderr, "Warning: Could not open debug log file: %v\n", err)
 			log.SetOutput(io.Discard)
@@ -1571,22 +3725,34 @@ func main() {
 		log.SetOutput(io.Discard)
 	}
 
-	// Validate required flags
-	if *host == "" {
-		fmt.Fprintf(os.Stderr, "Error: -host is required\n\n")
+	cfg, err := conn.resolve()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n\n", err)
 		flag.Usage()
 		os.Exit(1)
 	}
-
-	cfg := config{
-		host:     *host,
-		token:    *token,
-		loginID:  *user,
-		password: *pass,
-		teamID:   *teamID,
+	cfg.sidebarWidth = *sidebar
+	cfg.bell = *bell
+	cfg.images = *images
+	cfg.noConfirm = *noConfirm
+	if *timeFormat != "" {
+		cfg.timeFormat = *timeFormat
+	}
+	if *downloadDir != "" {
+		cfg.downloadDir = *downloadDir
+	}
+	if *urlWidth >= 0 {
+		cfg.urlWidth = *urlWidth
+	}
+	style = buildStyles(cfg.theme)
+	if keys, err = newKeymap(cfg.keys); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
 
-	p := tea.NewProgram(initialModel(cfg))
+	// Signals are handled by the model, so that they clean up like ctrl+c
+	p := tea.NewProgram(initialModel(cfg), tea.WithMouseCellMotion(), tea.WithoutSignalHandler())
+	notifySignals(p)
 	if _, err := p.Run(); err != nil {
 		log.Fatal(err)
 	}
@@ -1599,6 +3765,43 @@ func min(a, b int) int {
 	return b
 }
 
+// fitWidth returns the longest prefix of s that fits in width display
+// columns, cutting only on rune boundaries.
+func fitWidth(s string, width int) string {
+	used := 0
+	for i, r := range s {
+		w := lipgloss.Width(string(r))
+		if used+w > width {
+			return s[:i]
+		}
+		used += w
+	}
+	return s
+}
+
+// padRight pads s with spaces to width display columns
+func padRight(s string, width int) string {
+	if w := lipgloss.Width(s); w < width {
+		return s + strings.Repeat(" ", width-w)
+	}
+	return s
+}
+
+// timeLayoutWidth returns the widest a time formatted with layout gets,
+// trying one- and two-digit days and hours, morning and afternoon, and
+// the longest month and weekday names
+func timeLayoutWidth(layout string) int {
+	width := 0
+	for _, t := range []time.Time{
+		time.Date(2006, time.September, 3, 9, 4, 5, 0, time.Local),
+		time.Date(2006, time.September, 13, 22, 44, 55, 999999999, time.Local),
+		time.Date(2006, time.September, 20, 12, 4, 5, 0, time.Local), // Wednesday
+	} {
+		width = max(width, lipgloss.Width(t.Format(layout)))
+	}
+	return width
+}
+
 func truncate(s string, maxLen int) string {
 	if len(s) <= maxLen {
 		return s
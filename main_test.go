@@ -0,0 +1,779 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+	comm "libcommunicator"
+
+	conf "termunicator/internal/config"
+)
+
+// fakePlatform is an in-memory chat server with one team. It stands in
+// for *comm.Platform so that tests can drive Update and View.
+type fakePlatform struct {
+	mu       sync.Mutex
+	channels []comm.Channel
+	messages map[string][]comm.Message // by channel, oldest first
+	users    map[string]comm.User
+	commands []string // slash commands run, as typed
+	reply    string   // what ExecuteCommand answers
+	sendErrs []error  // what the next sends fail with, in turn
+	nextID   int
+}
+
+// newFakePlatform returns a server with channels "town-square" (ID c1) and
+// "random" (c2), and the users me and alice
+func newFakePlatform() *fakePlatform {
+	return &fakePlatform{
+		channels: []comm.Channel{
+			{ID: "c1", Name: "town-square", DisplayName: "Town Square", Type: "O"},
+			{ID: "c2", Name: "random", DisplayName: "Random", Type: "O"},
+		},
+		messages: make(map[string][]comm.Message),
+		users: map[string]comm.User{
+			"me":    {ID: "me", Username: "me"},
+			"alice": {ID: "alice", Username: "alice"},
+		},
+	}
+}
+
+// post adds a message from userID to channelID, a minute after the one
+// before, and returns it
+func (f *fakePlatform) post(channelID, userID, text string) comm.Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextID++
+	msg := comm.Message{
+		ID:        fmt.Sprintf("m%d", f.nextID),
+		ChannelID: channelID,
+		SenderID:  userID,
+		Text:      text,
+		CreatedAt: time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC).Add(time.Duration(f.nextID) * time.Minute),
+	}
+	f.messages[channelID] = append(f.messages[channelID], msg)
+	return msg
+}
+
+var errNoMessage = errors.New("404 not found")
+
+func (f *fakePlatform) SetTeamID(teamID string) error { return nil }
+
+func (f *fakePlatform) GetTeams() ([]comm.Team, error) {
+	return []comm.Team{{ID: "t1", Name: "team", DisplayName: "Team"}}, nil
+}
+
+func (f *fakePlatform) GetChannels() ([]comm.Channel, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]comm.Channel(nil), f.channels...), nil
+}
+
+func (f *fakePlatform) GetChannelMembers(channelID string) ([]comm.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var users []comm.User
+	for _, u := range f.users {
+		users = append(users, u)
+	}
+	return users, nil
+}
+
+func (f *fakePlatform) GetChannelStats(channelID string) (*comm.ChannelStats, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return &comm.ChannelStats{MessageCount: len(f.messages[channelID])}, nil
+}
+
+func (f *fakePlatform) MarkChannelRead(channelID string) error { return nil }
+
+func (f *fakePlatform) GetMessages(channelID string, limit int) ([]comm.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	msgs := f.messages[channelID]
+	return append([]comm.Message(nil), msgs[max(len(msgs)-limit, 0):]...), nil
+}
+
+func (f *fakePlatform) GetMessagesBefore(channelID, beforeID string, limit int) ([]comm.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	msgs := f.messages[channelID]
+	for i, msg := range msgs {
+		if msg.ID == beforeID {
+			return append([]comm.Message(nil), msgs[max(i-limit, 0):i]...), nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakePlatform) GetMessage(messageID string) (*comm.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, msgs := range f.messages {
+		for _, msg := range msgs {
+			if msg.ID == messageID {
+				return &msg, nil
+			}
+		}
+	}
+	return nil, errNoMessage
+}
+
+func (f *fakePlatform) SearchMessages(teamID, query string) ([]comm.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var found []comm.Message
+	for _, msgs := range f.messages {
+		for _, msg := range msgs {
+			if strings.Contains(msg.Text, query) {
+				found = append(found, msg)
+			}
+		}
+	}
+	return found, nil
+}
+
+func (f *fakePlatform) GetPinnedMessages(channelID string) ([]comm.Message, error) {
+	return nil, nil
+}
+
+func (f *fakePlatform) SendMessage(channelID, text string) (*comm.Message, error) {
+	if err := f.sendErr(); err != nil {
+		return nil, err
+	}
+	msg := f.post(channelID, "me", text)
+	return &msg, nil
+}
+
+func (f *fakePlatform) SendReply(channelID, rootID, text string) (*comm.Message, error) {
+	if err := f.sendErr(); err != nil {
+		return nil, err
+	}
+	msg := f.post(channelID, "me", text)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	msgs := f.messages[channelID]
+	msgs[len(msgs)-1].Metadata = map[string]interface{}{"root_id": rootID}
+	msg = msgs[len(msgs)-1]
+	return &msg, nil
+}
+
+// sendErr returns the error the next send fails with, if any
+func (f *fakePlatform) sendErr() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.sendErrs) == 0 {
+		return nil
+	}
+	err := f.sendErrs[0]
+	f.sendErrs = f.sendErrs[1:]
+	return err
+}
+
+func (f *fakePlatform) EditMessage(messageID, text string) (*comm.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, msgs := range f.messages {
+		for i := range msgs {
+			if msgs[i].ID == messageID {
+				msgs[i].Text = text
+				msg := msgs[i]
+				return &msg, nil
+			}
+		}
+	}
+	return nil, errNoMessage
+}
+
+func (f *fakePlatform) ExecuteCommand(channelID, command string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.commands = append(f.commands, command)
+	return f.reply, nil
+}
+
+func (f *fakePlatform) AddReaction(messageID, emojiName string) error    { return nil }
+func (f *fakePlatform) RemoveReaction(messageID, emojiName string) error { return nil }
+func (f *fakePlatform) SendTyping(channelID string) error                { return nil }
+
+func (f *fakePlatform) GetUser(userID string) (*comm.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if u, ok := f.users[userID]; ok {
+		return &u, nil
+	}
+	return nil, errors.New("404 user not found")
+}
+
+func (f *fakePlatform) DownloadFile(fileID string) ([]byte, error) { return nil, errNoMessage }
+
+func (f *fakePlatform) GetUserStatus(userID string) (string, error) { return "online", nil }
+
+func (f *fakePlatform) SearchUsers(query string) ([]comm.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var found []comm.User
+	for _, u := range f.users {
+		if strings.HasPrefix(u.Username, query) {
+			found = append(found, u)
+		}
+	}
+	return found, nil
+}
+
+func (f *fakePlatform) NewEventStream(ctx context.Context, bufferSize int, debounce time.Duration) (*comm.EventStream, error) {
+	return nil, errors.New("no event stream in tests")
+}
+
+var _ platformAPI = (*fakePlatform)(nil)
+
+// cmdTimeout is how long run waits for a command. Ticks and the like take
+// longer and are dropped, as if they had not fired yet.
+const cmdTimeout = 20 * time.Millisecond
+
+// run feeds msgs to m, and then, as the bubbletea runtime would, the
+// messages its commands return, until there are none left
+func run(t testing.TB, m model, msgs ...tea.Msg) model {
+	t.Helper()
+	for steps := 0; len(msgs) > 0; steps++ {
+		if steps > 1000 {
+			t.Fatal("commands keep producing messages")
+		}
+		next, cmd := m.Update(msgs[0])
+		m = next.(model)
+		msgs = append(msgs[1:], execCmd(cmd)...)
+	}
+	return m
+}
+
+// execCmd runs cmd, and those of a batch, returning what they produce
+func execCmd(cmd tea.Cmd) []tea.Msg {
+	if cmd == nil {
+		return nil
+	}
+	done := make(chan tea.Msg, 1)
+	go func() { done <- cmd() }()
+	select {
+	case msg := <-done:
+		switch msg := msg.(type) {
+		case nil:
+			return nil
+		case tea.BatchMsg:
+			var msgs []tea.Msg
+			for _, c := range msg {
+				msgs = append(msgs, execCmd(c)...)
+			}
+			return msgs
+		case tea.QuitMsg:
+			return nil
+		}
+		return []tea.Msg{msg}
+	case <-time.After(cmdTimeout):
+		return nil
+	}
+}
+
+// press returns the key message bubbletea sends for key, named as in the
+// keymap: "enter", "alt+left", "ctrl+w", or text to type
+func press(key string) tea.KeyMsg {
+	alt := strings.HasPrefix(key, "alt+")
+	name := strings.TrimPrefix(key, "alt+")
+	types := map[string]tea.KeyType{
+		"enter": tea.KeyEnter, "esc": tea.KeyEscape, "tab": tea.KeyTab,
+		"backspace": tea.KeyBackspace, "up": tea.KeyUp, "down": tea.KeyDown,
+		"left": tea.KeyLeft, "right": tea.KeyRight, "home": tea.KeyHome,
+		"end": tea.KeyEnd, "pgup": tea.KeyPgUp, "pgdown": tea.KeyPgDown,
+		"f1": tea.KeyF1, "ctrl+a": tea.KeyCtrlA, "ctrl+e": tea.KeyCtrlE,
+		"ctrl+f": tea.KeyCtrlF, "ctrl+k": tea.KeyCtrlK, "ctrl+o": tea.KeyCtrlO,
+		"ctrl+t": tea.KeyCtrlT, "ctrl+u": tea.KeyCtrlU, "ctrl+w": tea.KeyCtrlW,
+		"ctrl+home": tea.KeyCtrlHome,
+	}
+	if kt, ok := types[name]; ok {
+		return tea.KeyMsg{Type: kt, Alt: alt}
+	}
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(name), Alt: alt}
+}
+
+// paste returns the key message bubbletea sends for pasted text
+func paste(text string) tea.KeyMsg {
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(text), Paste: true}
+}
+
+// typeKeys presses each key in turn
+func typeKeys(t testing.TB, m model, keys ...string) model {
+	t.Helper()
+	for _, key := range keys {
+		m = run(t, m, press(key))
+	}
+	return m
+}
+
+// newTestModel returns a model connected to f, sized width×height, with
+// its config and state directories in a fresh temporary directory
+func newTestModel(t testing.TB, f *fakePlatform, width, height int) model {
+	t.Helper()
+	return newTestModelConfig(t, f, config{urlWidth: -1}, width, height)
+}
+
+// newTestModelConfig is newTestModel with the command-line settings in cfg
+func newTestModelConfig(t testing.TB, f *fakePlatform, cfg config, width, height int) model {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	teams, _ := f.GetTeams()
+	me := f.users["me"]
+	m := initialModel(cfg)
+	return run(t, m,
+		tea.WindowSizeMsg{Width: width, Height: height},
+		connectedMsg{platform: f, close: func() {}, me: &me, teams: teams},
+	)
+}
+
+// openChannel selects the team and then its first channel, as pressing
+// space twice in the sidebar does
+func openChannel(t *testing.T, m model) model {
+	t.Helper()
+	m = typeKeys(t, m, " ", " ")
+	if m.current != 0 {
+		t.Fatalf("current channel = %d, want 0", m.current)
+	}
+	return m
+}
+
+// screen returns the view without colors
+func screen(m model) string {
+	return ansi.Strip(m.View())
+}
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func TestOpenChannelShowsMessages(t *testing.T) {
+	f := newFakePlatform()
+	f.post("c1", "alice", "hello there")
+	f.post("c2", "alice", "elsewhere")
+	m := openChannel(t, newTestModel(t, f, 100, 20))
+
+	view := screen(m)
+	if !strings.Contains(view, "<alice> hello there") {
+		t.Errorf("view does not show the channel's message:\n%s", view)
+	}
+	if strings.Contains(view, "elsewhere") {
+		t.Errorf("view shows another channel's message:\n%s", view)
+	}
+}
+
+func TestSendMessage(t *testing.T) {
+	f := newFakePlatform()
+	m := openChannel(t, newTestModel(t, f, 100, 20))
+	m = typeKeys(t, m, "h", "i", " ", "a", "l", "l", "enter")
+
+	if got := f.messages["c1"]; len(got) != 1 || got[0].Text != "hi all" {
+		t.Fatalf("server has %v, want one message \"hi all\"", got)
+	}
+	if m.input != "" {
+		t.Errorf("input = %q after sending, want empty", m.input)
+	}
+	if view := screen(m); !strings.Contains(view, "<me> hi all") || strings.Contains(view, "sending") {
+		t.Errorf("sent message not shown as sent:\n%s", view)
+	}
+}
+
+func TestNewMessageDeduplicated(t *testing.T) {
+	f := newFakePlatform()
+	f.post("c1", "alice", "first")
+	m := openChannel(t, newTestModel(t, f, 100, 20))
+
+	msg := f.post("c1", "alice", "second")
+	m = run(t, m, newMessageMsg(msg), newMessageMsg(msg))
+	if n := len(m.getDisplayMessages()); n != 2 {
+		t.Errorf("%d messages displayed after the same one arrived twice, want 2", n)
+	}
+}
+
+func TestScrollUpLoadsOlder(t *testing.T) {
+	f := newFakePlatform()
+	for i := 0; i < messageFetchLimit+10; i++ {
+		f.post("c1", "alice", fmt.Sprintf("message %d", i))
+	}
+	m := openChannel(t, newTestModel(t, f, 100, 20))
+	if n := len(m.messages); n != messageFetchLimit {
+		t.Fatalf("%d messages loaded, want %d", n, messageFetchLimit)
+	}
+
+	for i := 0; i < 2*messageFetchLimit && len(m.messages) == messageFetchLimit; i++ {
+		m = run(t, m, press("up"))
+	}
+	if n := len(m.messages); n != messageFetchLimit+10 {
+		t.Fatalf("%d messages loaded after scrolling to the top, want %d", n, messageFetchLimit+10)
+	}
+	// The cursor stays on the oldest message of the first page
+	if got := m.getDisplayMessages()[m.messageCursor].Text; got != "message 10" {
+		t.Errorf("cursor on %q after the older page arrived, want \"message 10\"", got)
+	}
+}
+
+func TestSlashCommandShowsReply(t *testing.T) {
+	f := newFakePlatform()
+	f.reply = "You are now away"
+	m := openChannel(t, newTestModel(t, f, 100, 20))
+	m = typeKeys(t, m, "/", "a", "w", "a", "y", "enter")
+
+	if len(f.commands) != 1 || f.commands[0] != "/away" {
+		t.Fatalf("commands run = %q, want [/away]", f.commands)
+	}
+	if !strings.Contains(screen(m), "You are now away") {
+		t.Errorf("status bar does not show the command's reply:\n%s", screen(m))
+	}
+}
+
+func TestMentionRingsBellInView(t *testing.T) {
+	f := newFakePlatform()
+	m := openChannel(t, newTestModel(t, f, 100, 20))
+	m.config.bell = true
+
+	m = run(t, m, newMessageMsg(f.post("c1", "alice", "@me here")))
+	if strings.HasPrefix(m.View(), "\a") {
+		t.Error("bell rang for a mention in the open channel")
+	}
+	m = run(t, m, newMessageMsg(f.post("c2", "alice", "ping @me")))
+	if !strings.HasPrefix(m.View(), "\a") {
+		t.Error("no bell in the view after a mention elsewhere")
+	}
+	m = run(t, m, tickMsg(time.Now()))
+	if strings.Contains(m.View(), "\a") {
+		t.Error("bell still in the view after the flash")
+	}
+}
+
+func TestQuestionMarkStartsMessage(t *testing.T) {
+	f := newFakePlatform()
+	f.post("c1", "alice", "hello")
+	m := openChannel(t, newTestModel(t, f, 100, 20))
+
+	m = typeKeys(t, m, "?", "x")
+	if m.showHelp || m.input != "?x" {
+		t.Fatalf("typing ?x: help shown = %v, input = %q; want the input ?x", m.showHelp, m.input)
+	}
+	m = typeKeys(t, m, "ctrl+u", "up", "?")
+	if !m.showHelp {
+		t.Errorf("? with a message selected did not open the help")
+	}
+	m = typeKeys(t, m, "esc", "down", "f1")
+	if !m.showHelp {
+		t.Errorf("f1 did not open the help")
+	}
+}
+
+func TestToggleRepliesKey(t *testing.T) {
+	f := newFakePlatform()
+	f.post("c1", "alice", "hello")
+	m := openChannel(t, newTestModel(t, f, 100, 20))
+
+	m = typeKeys(t, m, "T")
+	if m.showReplies || m.input != "T" {
+		t.Fatalf("typing T: inline replies = %v, input = %q; want the input T", m.showReplies, m.input)
+	}
+	m = typeKeys(t, m, "ctrl+u", "up", "T")
+	if !m.showReplies || m.messageCursor != 0 {
+		t.Errorf("T with a message selected: inline replies = %v, cursor = %d; want on, still 0", m.showReplies, m.messageCursor)
+	}
+	m = typeKeys(t, m, "ctrl+t")
+	if m.showReplies {
+		t.Error("ctrl+t did not toggle inline replies back off")
+	}
+}
+
+func TestDeleteMiddleMessage(t *testing.T) {
+	f := newFakePlatform()
+	m := openChannel(t, newTestModel(t, f, 100, 20))
+	var posted []comm.Message
+	for _, text := range []string{"one", "two", "three"} {
+		msg := f.post("c1", "alice", text)
+		posted = append(posted, msg)
+		m = run(t, m, newMessageMsg(msg))
+	}
+	m = typeKeys(t, m, "up", "up") // select "two"
+	if got := m.getDisplayMessages()[m.messageCursor].Text; got != "two" {
+		t.Fatalf("selected %q, want \"two\"", got)
+	}
+
+	m = run(t, m, eventMsg(&comm.Event{Type: comm.EventMessageDeleted, ChannelID: "c1", MessageID: posted[1].ID}))
+	display := m.getDisplayMessages()
+	if len(display) != 2 || display[0].Text != "one" || display[1].Text != "three" {
+		t.Fatalf("displayed %v after deleting \"two\", want one and three", display)
+	}
+	if m.messageCursor < 0 || m.messageCursor >= len(display) || display[m.messageCursor].Text != "three" {
+		t.Errorf("cursor = %d, want 1, on the message after the deleted one", m.messageCursor)
+	}
+	if m.scrollOffset < 0 || m.scrollOffset > m.maxScroll() {
+		t.Errorf("scrollOffset = %d, outside 0..%d", m.scrollOffset, m.maxScroll())
+	}
+	view := screen(m)
+	if strings.Contains(view, "two") || !strings.Contains(view, "one") || !strings.Contains(view, "three") {
+		t.Errorf("view after deleting \"two\":\n%s", view)
+	}
+}
+
+func TestWideTextFillsWidth(t *testing.T) {
+	f := newFakePlatform()
+	f.post("c1", "alice", strings.Repeat("😀", 80))
+	f.post("c1", "alice", strings.Repeat("crème brûlée ", 12))
+	f.post("c1", "alice", strings.Repeat("日本語のテキスト", 12))
+	m := openChannel(t, newTestModel(t, f, 100, 20))
+
+	mainWidth := m.layoutMainWidth()
+	for _, msg := range m.getDisplayMessages() {
+		for _, line := range m.renderMessage(msg, false, mainWidth) {
+			if w := ansi.StringWidth(line); w > mainWidth || w < mainWidth-1 {
+				t.Errorf("line %d wide in %d columns: %q", w, mainWidth, ansi.Strip(line))
+			}
+		}
+	}
+	for i, line := range strings.Split(m.View(), "\n") {
+		if w := ansi.StringWidth(line); w != 100 {
+			t.Errorf("view line %d is %d wide, want 100: %q", i, w, ansi.Strip(line))
+		}
+	}
+}
+
+func TestCursorMovesOverEmoji(t *testing.T) {
+	m := openChannel(t, newTestModel(t, newFakePlatform(), 100, 20))
+	m = run(t, m, paste("hi 😀😀 there 🎉"))
+	if m.cursorPos != 13 {
+		t.Fatalf("cursor after paste = %d, want 13, the rune count", m.cursorPos)
+	}
+
+	steps := []struct {
+		key  string
+		want int
+	}{
+		{"left", 12},
+		{"left", 11},
+		{"alt+left", 6},
+		{"alt+left", 3},
+		{"right", 4},
+		{"alt+right", 5},
+		{"alt+right", 11},
+		{"home", 0},
+		{"left", 0},
+		{"alt+right", 2},
+		{"ctrl+e", 13},
+		{"right", 13},
+		{"ctrl+a", 0},
+		{"alt+f", 2},
+		{"end", 13},
+		{"alt+b", 12},
+	}
+	for _, step := range steps {
+		from := m.cursorPos
+		m = typeKeys(t, m, step.key)
+		if m.cursorPos != step.want {
+			t.Fatalf("%s from %d: cursor = %d, want %d", step.key, from, m.cursorPos, step.want)
+		}
+	}
+
+	// Typing between the two emoji must not split either of them
+	m = typeKeys(t, m, "home", "alt+right", "right", "right", "x")
+	if want := "hi 😀x😀 there 🎉"; m.input != want || m.cursorPos != 5 {
+		t.Errorf("typed x at 4: input = %q, cursor = %d; want %q, 5", m.input, m.cursorPos, want)
+	}
+}
+
+func TestDeleteKeys(t *testing.T) {
+	tests := []struct {
+		input      string
+		moves      []string
+		key        string
+		want       string
+		wantCursor int
+	}{
+		{"hello world", nil, "ctrl+w", "hello ", 6},
+		{"hello world   ", nil, "ctrl+w", "hello ", 6},
+		{"hello", nil, "ctrl+w", "", 0},
+		{"hi 😀😀", nil, "ctrl+w", "hi ", 3},
+		{"日本語 テキスト  ", nil, "ctrl+w", "日本語 ", 4},
+		{"crème brûlée", []string{"alt+left", "left"}, "ctrl+w", " brûlée", 0},
+		{"hi 😀😀 there", []string{"alt+left"}, "ctrl+u", "there", 0},
+		{"hi 😀😀 there  ", nil, "ctrl+u", "", 0},
+		{"hi 😀😀 there", []string{"home", "right", "right", "right", "right"}, "ctrl+k", "hi 😀", 4},
+		{"日本語 テキスト", []string{"home"}, "ctrl+k", "", 0},
+		{"trailing   ", []string{"left", "left"}, "ctrl+k", "trailing ", 9},
+		{"end", nil, "ctrl+k", "end", 3},
+	}
+	m := openChannel(t, newTestModel(t, newFakePlatform(), 100, 20))
+	for _, tt := range tests {
+		m.input, m.cursorPos = "", 0
+		m = run(t, m, paste(tt.input))
+		m = typeKeys(t, m, tt.moves...)
+		m = typeKeys(t, m, tt.key)
+		if m.input != tt.want || m.cursorPos != tt.wantCursor {
+			t.Errorf("%s in %q after %v: input = %q, cursor = %d; want %q, %d",
+				tt.key, tt.input, tt.moves, m.input, m.cursorPos, tt.want, tt.wantCursor)
+		}
+	}
+}
+
+func TestTimeFormatKeepsIndent(t *testing.T) {
+	for _, layout := range []string{"15:04", "3:04 PM", "Mon Jan 2 15:04", "15:04:05.000"} {
+		f := newFakePlatform()
+		f.post("c1", "alice", "morning\nsecond line")
+		f.post("c1", "alice", "evening\nsecond line")
+		// One early short timestamp, one late long one
+		f.messages["c1"][0].CreatedAt = time.Date(2024, 5, 1, 9, 4, 0, 0, time.Local)
+		f.messages["c1"][1].CreatedAt = time.Date(2024, 5, 22, 22, 44, 55, 123e6, time.Local)
+		m := openChannel(t, newTestModelConfig(t, f, config{urlWidth: -1, timeFormat: layout}, 100, 20))
+
+		// The text of every line, first or continuation, starts in the
+		// same column
+		mainWidth := m.layoutMainWidth()
+		col := -1
+		for _, msg := range m.getDisplayMessages() {
+			lines := m.renderMessage(msg, false, mainWidth)
+			for i, want := range []string{msg.Text[:7], "second line"} {
+				line := ansi.Strip(lines[i])
+				at := strings.Index(line, want)
+				if at < 0 {
+					t.Fatalf("%q: line %q lacks %q", layout, line, want)
+				}
+				if c := ansi.StringWidth(line[:at]); col < 0 {
+					col = c
+				} else if c != col {
+					t.Errorf("%q: %q starts in column %d, want %d:\n%s", layout, want, c, col, strings.Join(lines, "\n"))
+				}
+			}
+		}
+	}
+}
+
+func TestResizeKeepsSelectionVisible(t *testing.T) {
+	f := newFakePlatform()
+	for i := range 30 {
+		f.post("c1", "alice", fmt.Sprintf("message %02d", i))
+	}
+	m := openChannel(t, newTestModel(t, f, 100, 40))
+	for range 25 {
+		m = typeKeys(t, m, "up")
+	}
+	selected := m.getDisplayMessages()[m.messageCursor].Text
+
+	for _, height := range []int{12, 8, 5, 40} {
+		m = run(t, m, tea.WindowSizeMsg{Width: 100, Height: height})
+		if m.scrollOffset < 0 || m.scrollOffset > m.maxScroll() {
+			t.Errorf("height %d: scrollOffset = %d, outside 0..%d", height, m.scrollOffset, m.maxScroll())
+		}
+		if got := m.getDisplayMessages()[m.messageCursor].Text; got != selected {
+			t.Fatalf("height %d: selection moved to %q, want %q", height, got, selected)
+		}
+		if view := screen(m); !strings.Contains(view, selected) {
+			t.Errorf("height %d: selected %q is off screen:\n%s", height, selected, view)
+		}
+	}
+}
+
+// BenchmarkRenderSidebar draws the sidebar with the selection halfway down
+// ever longer channel lists. Only the visible window is walked, so the
+// time per frame should stay about the same.
+func BenchmarkRenderSidebar(b *testing.B) {
+	for _, n := range []int{50, 500, 5000} {
+		b.Run(fmt.Sprintf("channels=%d", n), func(b *testing.B) {
+			f := newFakePlatform()
+			f.channels = nil
+			for i := range n {
+				f.channels = append(f.channels, comm.Channel{
+					ID: fmt.Sprintf("c%d", i), Name: fmt.Sprintf("channel-%d", i), Type: "O",
+				})
+			}
+			m := newTestModel(b, f, 120, 40)
+			m = typeKeys(b, m, " ")
+			for range n / 2 {
+				m = typeKeys(b, m, "down")
+			}
+			sidebar := m.layoutSidebarWidth()
+			b.ResetTimer()
+			for range b.N {
+				m.renderSidebar(sidebar)
+			}
+		})
+	}
+}
+
+func TestRemappedOldest(t *testing.T) {
+	saved := keys
+	t.Cleanup(func() { keys = saved })
+	var err error
+	if keys, err = newKeymap(map[string]conf.Keys{"oldest": {"ctrl+home"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	f := newFakePlatform()
+	for i := range 5 {
+		f.post("c1", "alice", fmt.Sprintf("message %d", i))
+	}
+	m := openChannel(t, newTestModel(t, f, 100, 20))
+	m = typeKeys(t, m, "home")
+	if m.messageCursor != -1 {
+		t.Errorf("home with oldest remapped selected message %d", m.messageCursor)
+	}
+	m = typeKeys(t, m, "ctrl+home")
+	if m.messageCursor != 0 {
+		t.Errorf("ctrl+home with nothing typed: cursor = %d, want 0, the oldest", m.messageCursor)
+	}
+	m = typeKeys(t, m, "end", "h", "i", "home", "x")
+	if m.input != "xhi" {
+		t.Errorf("home in the input: input = %q, want \"xhi\"", m.input)
+	}
+}
+
+func TestTypeNonASCII(t *testing.T) {
+	m := openChannel(t, newTestModel(t, newFakePlatform(), 100, 20))
+	m = typeKeys(t, m, "c", "r", "è", "m", "e", " ", "😀", "日本")
+	if want := "crème 😀日本"; m.input != want || m.cursorPos != 9 {
+		t.Fatalf("typed %q, cursor %d; want %q, 9", m.input, m.cursorPos, want)
+	}
+	m = typeKeys(t, m, "alt+left", "é")
+	if want := "crème é😀日本"; m.input != want || m.cursorPos != 7 {
+		t.Errorf("typed é before the emoji: %q, cursor %d; want %q, 7", m.input, m.cursorPos, want)
+	}
+
+	// Named keys and alt combinations type nothing
+	before := m.input
+	m = run(t, m, tea.KeyMsg{Type: tea.KeyInsert}, tea.KeyMsg{Type: tea.KeyF5}, press("alt+x"), press("\x07"))
+	if m.input != before {
+		t.Errorf("insert, f5, alt+x and a control character typed %q", m.input)
+	}
+}
+
+func TestInputLineFits(t *testing.T) {
+	m := openChannel(t, newTestModel(t, newFakePlatform(), 100, 20))
+	m = run(t, m, paste(strings.Repeat("日本語😀", 30)+"\nmore"))
+	for _, width := range []int{10, 33, 34, 35, 60} {
+		line := ansi.Strip(m.renderInput(width, "town-square"))
+		if !utf8.ValidString(line) {
+			t.Errorf("width %d: input line %q is not valid UTF-8", width, line)
+		}
+		if w := ansi.StringWidth(line); w > width || w < width-1 {
+			t.Errorf("width %d: input line is %d wide: %q", width, w, line)
+		}
+	}
+
+	// The cursor is never cut in half
+	m = typeKeys(t, m, "home")
+	line := ansi.Strip(m.renderInput(16, "town-square"))
+	if want := "[town-square] █"; line != want {
+		t.Errorf("input line %q, want %q", line, want)
+	}
+}
@@ -0,0 +1,471 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// span is a run of message text sharing one inline style
+type span struct {
+	text   string
+	bold   bool
+	italic bool
+	code   bool // `inline code` or a line of a fenced block
+	// mention is mentionNone, or an @username token naming someone else
+	// (mentionOther) or the current user (mentionMe)
+	mention int
+	match   bool // matches the message search
+	link    bool // a URL, maybe shortened for display (see markLinks)
+}
+
+const (
+	mentionNone = iota
+	mentionOther
+	mentionMe
+)
+
+// bodyLine is one screen line of message text
+type bodyLine struct {
+	spans []span
+	code  bool // inside a ``` fenced block: never styled, never wrapped
+}
+
+// parseBody splits message text into screen lines. Fence lines (```)
+// are dropped and the lines between them kept literal as code; the rest is
+// parsed for inline Markdown. There is always at least one line.
+func parseBody(text string) []bodyLine {
+	var lines []bodyLine
+	var prose []string
+	flushProse := func() {
+		if prose == nil {
+			return
+		}
+		for _, spans := range splitSpanLines(parseInline(strings.Join(prose, "\n"))) {
+			lines = append(lines, bodyLine{spans: spans})
+		}
+		prose = nil
+	}
+
+	inCode := false
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			flushProse()
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			code := strings.ReplaceAll(line, "\t", "    ")
+			lines = append(lines, bodyLine{spans: []span{{text: code, code: true}}, code: true})
+			continue
+		}
+		prose = append(prose, line)
+	}
+	flushProse()
+	if len(lines) == 0 {
+		lines = append(lines, bodyLine{})
+	}
+	return lines
+}
+
+// parseInline splits Markdown text into styled spans: **bold**, *italic*,
+// _italic_ and `code`. Markers are dropped from the output; unmatched
+// markers are kept as literal text. Spans may contain newlines.
+func parseInline(text string) []span {
+	return mergeSpans(parseSpans([]rune(text), span{}))
+}
+
+// parseSpans parses s with the styles already in effect from outer markers
+func parseSpans(s []rune, outer span) []span {
+	var spans []span
+	var literal []rune
+	flush := func() {
+		if len(literal) > 0 {
+			spans = append(spans, span{text: string(literal), bold: outer.bold, italic: outer.italic})
+			literal = nil
+		}
+	}
+
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '`':
+			if j := indexRune(s, i+1, '`'); j > i+1 {
+				flush()
+				spans = append(spans, span{text: string(s[i+1 : j]), bold: outer.bold, italic: outer.italic, code: true})
+				i = j
+				continue
+			}
+			literal = append(literal, s[i])
+		case s[i] == '*' && i+2 < len(s) && s[i+1] == '*' && !unicode.IsSpace(s[i+2]):
+			if j := closingDouble(s, i+2); j > i+2 {
+				flush()
+				inner := outer
+				inner.bold = true
+				spans = append(spans, parseSpans(s[i+2:j], inner)...)
+				i = j + 1
+				continue
+			}
+			literal = append(literal, '*', '*')
+			i++
+		case opensItalic(s, i):
+			if j := closingSingle(s, i+1, s[i]); j > i+1 {
+				flush()
+				inner := outer
+				inner.italic = true
+				spans = append(spans, parseSpans(s[i+1:j], inner)...)
+				i = j
+				continue
+			}
+			literal = append(literal, s[i])
+		default:
+			literal = append(literal, s[i])
+		}
+	}
+	flush()
+	return spans
+}
+
+// indexRune returns the index of the first c in s at or after from, or -1
+func indexRune(s []rune, from int, c rune) int {
+	for j := from; j < len(s); j++ {
+		if s[j] == c {
+			return j
+		}
+	}
+	return -1
+}
+
+// closingDouble returns the index of the "**" closing a bold run that
+// starts at from, or -1. In "***x***" the last pair closes.
+func closingDouble(s []rune, from int) int {
+	for j := from; j+1 < len(s); j++ {
+		if s[j] == '*' && s[j+1] == '*' && !unicode.IsSpace(s[j-1]) && (j+2 == len(s) || s[j+2] != '*') {
+			return j
+		}
+	}
+	return -1
+}
+
+// closingSingle returns the index of the single marker c closing an italic
+// run that starts at from, or -1. "**" pairs inside belong to bold, and
+// "_" only closes at the end of a word, so snake_case stays literal.
+func closingSingle(s []rune, from int, c rune) int {
+	for j := from; j < len(s); j++ {
+		if s[j] != c {
+			continue
+		}
+		if c == '*' && j+1 < len(s) && s[j+1] == '*' {
+			j++ // skip the bold pair
+			continue
+		}
+		if unicode.IsSpace(s[j-1]) {
+			continue
+		}
+		if c == '*' {
+			return j
+		}
+		if j+1 == len(s) || !isWordRune(s[j+1]) {
+			return j
+		}
+	}
+	return -1
+}
+
+// opensItalic reports whether s[i] can open an italic run: a marker
+// followed by text, and for "_" not in the middle of a word.
+func opensItalic(s []rune, i int) bool {
+	if s[i] != '*' && s[i] != '_' {
+		return false
+	}
+	if i+1 >= len(s) || unicode.IsSpace(s[i+1]) {
+		return false
+	}
+	return s[i] == '*' || i == 0 || !isWordRune(s[i-1])
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// isMentionRune reports whether r can be part of a Mattermost username
+func isMentionRune(r rune) bool {
+	return isWordRune(r) || r == '.' || r == '_' || r == '-'
+}
+
+// mentionedNames returns the usernames @-mentioned in text
+func mentionedNames(text string) []string {
+	var names []string
+	s := []rune(text)
+	for i := 0; i < len(s); i++ {
+		if s[i] != '@' || (i > 0 && isMentionRune(s[i-1])) {
+			continue
+		}
+		j := i + 1
+		for j < len(s) && isMentionRune(s[j]) {
+			j++
+		}
+		// A trailing dot ends the sentence, not the name
+		for j > i+1 && s[j-1] == '.' {
+			j--
+		}
+		if j > i+1 {
+			names = append(names, string(s[i+1:j]))
+		}
+		i = j - 1
+	}
+	return names
+}
+
+// urlTrailing are characters that end a sentence rather than a URL
+const urlTrailing = ".,;:!?)]}>'\""
+
+// findURLs returns the http and https URLs in text, in order
+func findURLs(text string) []string {
+	var urls []string
+	for _, field := range strings.Fields(text) {
+		i := strings.Index(field, "http://")
+		if j := strings.Index(field, "https://"); j >= 0 && (i < 0 || j < i) {
+			i = j
+		}
+		if i < 0 {
+			continue
+		}
+		url := strings.TrimRight(field[i:], urlTrailing)
+		if strings.HasSuffix(url, "://") {
+			continue
+		}
+		urls = append(urls, url)
+	}
+	return urls
+}
+
+// shortenURL returns url as shown when it is wider than width columns:
+// without the scheme and with the middle of the path elided, as in
+// "example.com/…/page", and cut to width if that is still too wide. A
+// width of 0 or less never shortens.
+func shortenURL(url string, width int) string {
+	if width <= 0 || lipgloss.Width(url) <= width {
+		return url
+	}
+	rest := url[strings.Index(url, "://")+len("://"):]
+	host, path, _ := strings.Cut(rest, "/")
+	path = strings.TrimRight(path, "/")
+	short := host
+	switch last := path[strings.LastIndex(path, "/")+1:]; {
+	case last == "":
+	case last == path:
+		short += "/" + last
+	default:
+		short += "/…/" + last
+	}
+	if lipgloss.Width(short) > width {
+		short = fitWidth(short, max(width-1, 0)) + "…"
+	}
+	return short
+}
+
+// markLinks splits the URLs out of prose spans so they can be styled,
+// shortening those wider than width (see shortenURL). The full URLs stay
+// in the message text, which is what the open and copy keys use.
+func markLinks(spans []span, width int) []span {
+	var out []span
+	for _, sp := range spans {
+		urls := findURLs(sp.text)
+		if sp.code || len(urls) == 0 {
+			out = append(out, sp)
+			continue
+		}
+		rest := sp.text
+		for _, url := range urls {
+			i := strings.Index(rest, url)
+			if i < 0 {
+				continue
+			}
+			if i > 0 {
+				before := sp
+				before.text = rest[:i]
+				out = append(out, before)
+			}
+			tok := sp
+			tok.text = shortenURL(url, width)
+			tok.link = true
+			out = append(out, tok)
+			rest = rest[i+len(url):]
+		}
+		if rest != "" {
+			after := sp
+			after.text = rest
+			out = append(out, after)
+		}
+	}
+	return out
+}
+
+// mentionsMe reports whether text @-mentions username
+func mentionsMe(text, username string) bool {
+	if username == "" {
+		return false
+	}
+	for _, name := range mentionedNames(text) {
+		if strings.EqualFold(name, username) {
+			return true
+		}
+	}
+	return false
+}
+
+// markMentions splits @username tokens out of prose spans so they can be
+// styled; me is the current user's username. Code is left alone.
+func markMentions(spans []span, me string) []span {
+	var out []span
+	for _, sp := range spans {
+		if sp.code || !strings.Contains(sp.text, "@") {
+			out = append(out, sp)
+			continue
+		}
+		rest := sp.text
+		for _, name := range mentionedNames(sp.text) {
+			token := "@" + name
+			i := strings.Index(rest, token)
+			if i < 0 {
+				continue
+			}
+			if i > 0 {
+				before := sp
+				before.text = rest[:i]
+				out = append(out, before)
+			}
+			tok := sp
+			tok.text = token
+			tok.mention = mentionOther
+			if me != "" && strings.EqualFold(name, me) {
+				tok.mention = mentionMe
+			}
+			out = append(out, tok)
+			rest = rest[i+len(token):]
+		}
+		if rest != "" {
+			after := sp
+			after.text = rest
+			out = append(out, after)
+		}
+	}
+	return out
+}
+
+// mergeSpans joins neighbouring spans with the same style
+func mergeSpans(spans []span) []span {
+	var merged []span
+	for _, sp := range spans {
+		if n := len(merged); n > 0 && merged[n-1].bold == sp.bold && merged[n-1].italic == sp.italic && merged[n-1].code == sp.code {
+			merged[n-1].text += sp.text
+			continue
+		}
+		merged = append(merged, sp)
+	}
+	return merged
+}
+
+// splitSpanLines splits spans at newlines, so styles opened on one line
+// carry over to the next.
+func splitSpanLines(spans []span) [][]span {
+	lines := [][]span{nil}
+	for _, sp := range spans {
+		parts := strings.Split(sp.text, "\n")
+		for i, part := range parts {
+			if i > 0 {
+				lines = append(lines, nil)
+			}
+			if part != "" {
+				piece := sp
+				piece.text = part
+				lines[len(lines)-1] = append(lines[len(lines)-1], piece)
+			}
+		}
+	}
+	return lines
+}
+
+// spansWidth returns the display width of spans
+func spansWidth(spans []span) int {
+	w := 0
+	for _, sp := range spans {
+		w += lipgloss.Width(sp.text)
+	}
+	return w
+}
+
+// fitSpans truncates spans to width display columns, adding an ellipsis
+// when cut and keeping each span's style. It never splits a rune, and wide
+// characters (CJK, emoji) count as two columns.
+func fitSpans(spans []span, width int) []span {
+	if width < 0 {
+		width = 0
+	}
+	if spansWidth(spans) <= width {
+		return spans
+	}
+	ellipsis := ""
+	if width > minTruncateWidth {
+		width -= ellipsisLen
+		ellipsis = "..."
+	}
+	var fitted []span
+	for _, sp := range spans {
+		w := lipgloss.Width(sp.text)
+		if w > width {
+			sp.text = fitWidth(sp.text, width)
+			fitted = append(fitted, sp)
+			break
+		}
+		fitted = append(fitted, sp)
+		width -= w
+	}
+	if ellipsis != "" {
+		fitted = append(fitted, span{text: ellipsis})
+	}
+	return fitted
+}
+
+// fitCode truncates a code line to width, marking the cut with »
+func fitCode(text string, width int) string {
+	if lipgloss.Width(text) <= width {
+		return text
+	}
+	if width < 1 {
+		return ""
+	}
+	return fitWidth(text, width-1) + "»"
+}
+
+// renderSpans renders spans on top of base, adding bold/italic per span.
+// Code keeps its own background so it stands out even when highlighted.
+func renderSpans(spans []span, base lipgloss.Style) string {
+	var b strings.Builder
+	for _, sp := range spans {
+		st := base
+		switch {
+		case sp.code:
+			st = style.code
+		case sp.mention == mentionMe:
+			st = style.highlighted.Bold(true)
+		case sp.mention == mentionOther:
+			st = st.Inherit(style.activity)
+		}
+		if sp.bold {
+			st = st.Bold(true)
+		}
+		if sp.italic {
+			st = st.Italic(true)
+		}
+		if sp.link {
+			st = st.Underline(true)
+		}
+		if sp.match {
+			st = st.Reverse(true)
+		}
+		b.WriteString(st.Render(sp.text))
+	}
+	return b.String()
+}
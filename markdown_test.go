@@ -0,0 +1,124 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseInline(t *testing.T) {
+	tests := []struct {
+		text string
+		want []span
+	}{
+		{"plain", []span{{text: "plain"}}},
+		{"**bold** text", []span{{text: "bold", bold: true}, {text: " text"}}},
+		{"x**y**z", []span{{text: "x"}, {text: "y", bold: true}, {text: "z"}}},
+		{"*it* and _it_", []span{{text: "it", italic: true}, {text: " and "}, {text: "it", italic: true}}},
+		// Nested
+		{"***x***", []span{{text: "x", bold: true, italic: true}}},
+		{"**a *b* c**", []span{{text: "a ", bold: true}, {text: "b", bold: true, italic: true}, {text: " c", bold: true}}},
+		{"*a **b** c*", []span{{text: "a ", italic: true}, {text: "b", bold: true, italic: true}, {text: " c", italic: true}}},
+		// Unmatched markers stay literal
+		{"**x", []span{{text: "**x"}}},
+		{"*x", []span{{text: "*x"}}},
+		{"2 * 3 * 4", []span{{text: "2 * 3 * 4"}}},
+		// Underscores inside words are not italic
+		{"snake_case_name", []span{{text: "snake_case_name"}}},
+		{"call my_func_here now", []span{{text: "call my_func_here now"}}},
+		// Nothing is parsed inside code
+		{"a `co*de*` b", []span{{text: "a "}, {text: "co*de*", code: true}, {text: " b"}}},
+		{"**`x`**", []span{{text: "x", bold: true, code: true}}},
+	}
+	for _, tt := range tests {
+		if got := parseInline(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseInline(%q) =\n  %+v, want\n  %+v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestFitWidth(t *testing.T) {
+	tests := []struct {
+		s     string
+		width int
+		want  string
+	}{
+		{"hello", 10, "hello"},
+		{"hello", 3, "hel"},
+		{"😀😀😀", 4, "😀😀"},
+		{"😀😀😀", 5, "😀😀"}, // half an emoji does not fit
+		{"café crème", 4, "café"},
+		{"café x", 4, "café"}, // the combining accent takes no column
+		{"日本語テキスト", 6, "日本語"},
+		{"日本語テキスト", 7, "日本語"},
+		{"a日b", 2, "a"},
+	}
+	for _, tt := range tests {
+		if got := fitWidth(tt.s, tt.width); got != tt.want {
+			t.Errorf("fitWidth(%q, %d) = %q, want %q", tt.s, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestFitSpans(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+	}{
+		{"emoji", strings.Repeat("😀 ", 40)},
+		{"accented Latin", strings.Repeat("éàüñç ", 20)},
+		{"Japanese", strings.Repeat("日本語のテキスト", 10)},
+	}
+	for _, tt := range tests {
+		for width := 10; width <= 40; width++ {
+			spans := []span{{text: "<nick> "}, {text: tt.text, bold: true}}
+			got := spansWidth(fitSpans(spans, width))
+			// A wide rune that would straddle the edge is left out
+			if got > width || got < width-1 {
+				t.Errorf("%s at %d columns: %d wide", tt.name, width, got)
+			}
+		}
+	}
+}
+
+func TestMentionsMe(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"hey @me, look", true},
+		{"@me", true},
+		{"ping @ME please", true}, // usernames ignore case
+		{"thanks @me.", true},     // the period ends the sentence
+		{"see you @me!", true},
+		{"hello everyone", false},
+		{"me too", false},
+		{"mail@me", false}, // an address, not a mention
+		{"write to me@example.com", false},
+		{"@meadow is here", false},
+		{"@alice and @bob", false},
+	}
+	for _, tt := range tests {
+		if got := mentionsMe(tt.text, "me"); got != tt.want {
+			t.Errorf("mentionsMe(%q, \"me\") = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+	if mentionsMe("hey @me", "") {
+		t.Error("mentionsMe with no username = true")
+	}
+}
+
+func TestMarkMentions(t *testing.T) {
+	got := markMentions([]span{{text: "hi @Me and @alice, not mail@me"}, {text: "@me", code: true}}, "me")
+	want := []span{
+		{text: "hi "},
+		{text: "@Me", mention: mentionMe},
+		{text: " and "},
+		{text: "@alice", mention: mentionOther},
+		{text: ", not mail@me"},
+		{text: "@me", code: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("markMentions =\n  %+v, want\n  %+v", got, want)
+	}
+}
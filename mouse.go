@@ -0,0 +1,63 @@
+package main
+
+import (
+	"log"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// handleMouse handles clicks and the wheel. A click on a sidebar entry
+// opens it, as if selected and opened from the keyboard; a click on the
+// message area focuses it. The wheel scrolls the messages.
+func (m model) handleMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
+	if !m.connected || m.showHelp {
+		return m, nil
+	}
+	sidebar := m.layoutSidebarWidth()
+	inSidebar := msg.X < sidebar
+
+	switch {
+	case msg.Action == tea.MouseActionPress && msg.Button == tea.MouseButtonLeft:
+		if !inSidebar {
+			if m.focus == focusSidebar {
+				m.filtering = false
+				m.focus = focusMain
+				if m.threadRootID != "" {
+					m.focus = focusThread
+				}
+			}
+			return m, nil
+		}
+		rows := m.sidebarRows(sidebar)
+		if msg.Y < 0 || msg.Y >= len(rows) || rows[msg.Y].item == nil {
+			return m, nil
+		}
+		item := rows[msg.Y].item
+		m.filtering = false
+		m.focus = focusSidebar
+		m.selected = item.index
+		m.selectedType = item.itemType
+		return m.openSelected()
+
+	case msg.Button == tea.MouseButtonWheelUp && !inSidebar:
+		return m.scrollMessages(mouseWheelLines)
+
+	case msg.Button == tea.MouseButtonWheelDown && !inSidebar:
+		return m.scrollMessages(-mouseWheelLines)
+	}
+	return m, nil
+}
+
+// scrollMessages scrolls the message area by n lines, up if positive.
+// Scrolling up past the oldest loaded message fetches older ones.
+func (m model) scrollMessages(n int) (tea.Model, tea.Cmd) {
+	if n > 0 && m.scrollOffset >= m.maxScroll() {
+		if m.threadRootID == "" {
+			log.Printf("wheel: fetching older messages (at top)")
+			return m, m.loadOlder()
+		}
+		return m, nil
+	}
+	m.scrollOffset = m.clampScrollOffset(m.scrollOffset + n)
+	return m, m.loadNewer()
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	comm "libcommunicator"
+)
+
+// maxSendAttempts is how often a message is tried before it is marked as
+// not sent and left for the user to resend (actReply, r)
+const maxSendAttempts = 4
+
+// pendingSend is a message typed and sent but not yet confirmed by the
+// server. It is shown under a temporary ID until the real one arrives.
+type pendingSend struct {
+	id        string // temporary ID, "pending-N"
+	channelID string
+	rootID    string // thread replied to ("" = the channel)
+	text      string
+	createdAt time.Time
+	attempts  int  // failed attempts so far
+	failed    bool // gave up after maxSendAttempts
+}
+
+// sentMsg reports that the server accepted an outbox entry
+type sentMsg struct {
+	id  string
+	msg *comm.Message
+}
+
+// sendFailedMsg reports a failed attempt to send an outbox entry
+type sendFailedMsg struct {
+	id  string
+	err error
+}
+
+// retrySendMsg fires when it is time to try an outbox entry again
+type retrySendMsg struct {
+	id string
+}
+
+// sendMessage sends p as a message or, with a root, as a thread reply
+func sendMessage(platform platformAPI, p pendingSend) tea.Cmd {
+	return func() tea.Msg {
+		var msg *comm.Message
+		var err error
+		if p.rootID != "" {
+			msg, err = platform.SendReply(p.channelID, p.rootID, p.text)
+		} else {
+			msg, err = platform.SendMessage(p.channelID, p.text)
+		}
+		if err != nil {
+			return sendFailedMsg{id: p.id, err: err}
+		}
+		return sentMsg{id: p.id, msg: msg}
+	}
+}
+
+// queueSend adds text to the outbox, where it is shown right away, and
+// starts sending it
+func (m *model) queueSend(channelID, rootID, text string) tea.Cmd {
+	m.nextPendingID++
+	p := pendingSend{
+		id:        fmt.Sprintf("pending-%d", m.nextPendingID),
+		channelID: channelID,
+		rootID:    rootID,
+		text:      text,
+		createdAt: time.Now(),
+	}
+	m.outbox = append(m.outbox, p)
+	m.displayMsgsDirty = true
+	return sendMessage(m.platform, p)
+}
+
+// outboxIndex returns the position of the outbox entry with this
+// temporary ID, or -1
+func (m model) outboxIndex(id string) int {
+	for i, p := range m.outbox {
+		if p.id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// isPending reports whether id is the temporary ID of an outbox entry
+func (m model) isPending(id string) bool {
+	return m.outboxIndex(id) >= 0
+}
+
+// dropPending removes the outbox entry at i
+func (m *model) dropPending(i int) {
+	m.outbox = append(m.outbox[:i:i], m.outbox[i+1:]...)
+	m.displayMsgsDirty = true
+}
+
+// pendingMessages returns the outbox entries for what is on screen, the
+// open channel or thread, as messages to display after the loaded ones
+func (m model) pendingMessages() []comm.Message {
+	if m.current < 0 || m.current >= len(m.channels) {
+		return nil
+	}
+	var msgs []comm.Message
+	for _, p := range m.outbox {
+		if p.channelID != m.channels[m.current].ID || p.rootID != m.threadRootID {
+			continue
+		}
+		msgs = append(msgs, comm.Message{
+			ID:        p.id,
+			ChannelID: p.channelID,
+			SenderID:  m.myUserID,
+			Text:      p.text,
+			CreatedAt: p.createdAt,
+		})
+	}
+	return msgs
+}
+
+// reconcileSent drops the outbox entry msg confirms, when the posted
+// event brings our message before the send itself has returned
+func (m *model) reconcileSent(msg comm.Message) {
+	if m.myUserID == "" || msg.SenderID != m.myUserID {
+		return
+	}
+	for i, p := range m.outbox {
+		if !p.failed && p.channelID == msg.ChannelID && p.rootID == m.replyRoot(msg) && p.text == msg.Text {
+			m.dropPending(i)
+			return
+		}
+	}
+}
+
+// resend sends a message that failed to send again, from scratch. It
+// returns nil unless id is such a message.
+func (m *model) resend(id string) tea.Cmd {
+	i := m.outboxIndex(id)
+	if i < 0 || !m.outbox[i].failed {
+		return nil
+	}
+	m.outbox[i].failed = false
+	m.outbox[i].attempts = 0
+	m.msgPaneDirty = true
+	return sendMessage(m.platform, m.outbox[i])
+}
+
+// refused reports whether the server turned a send down for good, with a
+// 4xx status, so that trying again would fail the same way. A timeout
+// (408) or being told to slow down (429) is worth another try.
+func refused(err error) bool {
+	status := httpStatus(err)
+	return status >= 400 && status < 500 && status != 408 && status != 429
+}
+
+// handleOutbox handles the send results and retries, reporting whether
+// msg was one of them
+func (m model) handleOutbox(msg tea.Msg) (tea.Model, tea.Cmd, bool) {
+	switch msg := msg.(type) {
+	case sentMsg:
+		if i := m.outboxIndex(msg.id); i >= 0 {
+			m.dropPending(i)
+		}
+		// Put the real message where the pending one was, unless the
+		// posted event already brought it
+		if msg.msg == nil || m.current < 0 || m.current >= len(m.channels) || msg.msg.ChannelID != m.channels[m.current].ID {
+			return m, nil, true
+		}
+		if !m.hasMessage(msg.msg.ID) && !m.newerTrimmed {
+			m.messages = append(m.messages, *msg.msg)
+			m.displayMsgsDirty = true
+			if m.scrollOffset == 0 {
+				m.trimMessages(false)
+			} else {
+				m.scrollOffset = m.clampScrollOffset(m.scrollOffset)
+			}
+		}
+		return m, nil, true
+
+	case sendFailedMsg:
+		i := m.outboxIndex(msg.id)
+		if i < 0 {
+			return m, nil, true
+		}
+		p := &m.outbox[i]
+		p.attempts++
+		log.Printf("send %s attempt %d: %v", p.id, p.attempts, msg.err)
+		if p.attempts >= maxSendAttempts || refused(msg.err) {
+			p.failed = true
+			m.msgPaneDirty = true
+			m.reportError("send message", msg.err)
+			return m, nil, true
+		}
+		// Same backoff as reconnecting: 1s, 2s, 4s...
+		id := p.id
+		return m, tea.Tick(reconnectDelay(p.attempts-1), func(time.Time) tea.Msg { return retrySendMsg{id: id} }), true
+
+	case retrySendMsg:
+		i := m.outboxIndex(msg.id)
+		if i < 0 || m.outbox[i].failed {
+			return m, nil, true
+		}
+		return m, sendMessage(m.platform, m.outbox[i]), true
+	}
+	return m, nil, false
+}
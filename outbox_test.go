@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	comm "libcommunicator"
+)
+
+var errOffline = errors.New("dial tcp: connection refused")
+
+// retrySend fires the retry of the only outbox entry, as its tick would
+func retrySend(t *testing.T, m model) model {
+	t.Helper()
+	if len(m.outbox) != 1 {
+		t.Fatalf("%d messages in the outbox, want 1", len(m.outbox))
+	}
+	return run(t, m, retrySendMsg{id: m.outbox[0].id})
+}
+
+func TestSendRetries(t *testing.T) {
+	f := newFakePlatform()
+	m := openChannel(t, newTestModel(t, f, 100, 20))
+	f.sendErrs = []error{errOffline, errOffline}
+
+	m = typeKeys(t, m, "h", "i", "enter")
+	if len(m.outbox) != 1 || m.outbox[0].failed || m.outbox[0].attempts != 1 {
+		t.Fatalf("after a failed send: outbox %+v, want one entry to retry", m.outbox)
+	}
+	if view := screen(m); !strings.Contains(view, "hi (sending…)") {
+		t.Errorf("view does not show the message as sending:\n%s", view)
+	}
+	m = retrySend(t, m)
+	m = retrySend(t, m)
+	if len(m.outbox) != 0 {
+		t.Fatalf("outbox %+v after the third attempt went through", m.outbox)
+	}
+	if view := screen(m); !strings.Contains(view, "<me> hi") || strings.Contains(view, "sending") {
+		t.Errorf("view after sending:\n%s", view)
+	}
+}
+
+func TestSendGivesUp(t *testing.T) {
+	f := newFakePlatform()
+	m := openChannel(t, newTestModel(t, f, 100, 20))
+	f.sendErrs = []error{errOffline, errOffline, errOffline, errOffline}
+
+	m = typeKeys(t, m, "h", "i", "enter")
+	for range maxSendAttempts - 1 {
+		m = retrySend(t, m)
+	}
+	if len(m.outbox) != 1 || !m.outbox[0].failed || m.outbox[0].attempts != maxSendAttempts {
+		t.Fatalf("after %d failed attempts: outbox %+v, want one failed entry", maxSendAttempts, m.outbox)
+	}
+	if m.err == nil {
+		t.Error("giving up reported no error")
+	}
+	if view := screen(m); !strings.Contains(view, "not sent") {
+		t.Errorf("view does not mark the message not sent:\n%s", view)
+	}
+
+	// A retry still on its way does nothing; resending starts over
+	m = retrySend(t, m)
+	if !m.outbox[0].failed || m.outbox[0].attempts != maxSendAttempts {
+		t.Fatal("a late retry sent a message that was given up")
+	}
+	cmd := m.resend(m.outbox[0].id)
+	m = run(t, m, execCmd(cmd)...)
+	if len(m.outbox) != 0 {
+		t.Errorf("resend left outbox %+v", m.outbox)
+	}
+}
+
+func TestSendRefusedNotRetried(t *testing.T) {
+	for _, err := range []error{
+		errors.New("status 413: request entity too large"),
+		errors.New("status code: 403 forbidden"),
+		errors.New("400 Bad Request"),
+	} {
+		f := newFakePlatform()
+		m := openChannel(t, newTestModel(t, f, 100, 20))
+		f.sendErrs = []error{err}
+
+		m = typeKeys(t, m, "h", "i", "enter")
+		if len(m.outbox) != 1 || !m.outbox[0].failed || m.outbox[0].attempts != 1 {
+			t.Errorf("%v: outbox %+v, want one entry failed after one attempt", err, m.outbox)
+		}
+	}
+
+	// Timeouts and rate limits are tried again
+	for _, err := range []error{errors.New("status 429: too many requests"), errors.New("HTTP 408")} {
+		f := newFakePlatform()
+		m := openChannel(t, newTestModel(t, f, 100, 20))
+		f.sendErrs = []error{err}
+		m = typeKeys(t, m, "h", "i", "enter")
+		if len(m.outbox) != 1 || m.outbox[0].failed {
+			t.Errorf("%v: outbox %+v, want an entry to retry", err, m.outbox)
+		}
+	}
+}
+
+func TestReconcileSent(t *testing.T) {
+	f := newFakePlatform()
+	root := f.post("c1", "alice", "question")
+	m := openChannel(t, newTestModel(t, f, 100, 20))
+	f.sendErrs = []error{errOffline, errOffline}
+
+	// The same text pending in the channel and in the thread
+	cmd := m.queueSend("c1", "", "yes")
+	m = run(t, m, execCmd(cmd)...)
+	cmd = m.queueSend("c1", root.ID, "yes")
+	m = run(t, m, execCmd(cmd)...)
+	if len(m.outbox) != 2 {
+		t.Fatalf("outbox %+v, want both pending", m.outbox)
+	}
+
+	// The posted event for the reply clears the reply only
+	reply := comm.Message{ID: "r1", ChannelID: "c1", SenderID: "me", Text: "yes",
+		Metadata: map[string]interface{}{"root_id": root.ID}}
+	m = run(t, m, newMessageMsg(reply))
+	if len(m.outbox) != 1 || m.outbox[0].rootID != "" {
+		t.Fatalf("after the reply was posted: outbox %+v, want the channel message left", m.outbox)
+	}
+
+	// Someone else's identical message clears nothing
+	m = run(t, m, newMessageMsg(comm.Message{ID: "a1", ChannelID: "c1", SenderID: "alice", Text: "yes"}))
+	if len(m.outbox) != 1 {
+		t.Fatalf("alice's message cleared outbox %+v", m.outbox)
+	}
+
+	m = run(t, m, newMessageMsg(comm.Message{ID: "m9", ChannelID: "c1", SenderID: "me", Text: "yes"}))
+	if len(m.outbox) != 0 {
+		t.Errorf("after the message was posted: outbox %+v, want it empty", m.outbox)
+	}
+}
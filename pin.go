@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strings"
+
+	comm "libcommunicator"
+)
+
+// pinnedHeight is the line the pinned message takes above the messages
+const pinnedHeight = 1
+
+// togglePin pins msg above the message pane, or unpins it if it is the
+// pinned one
+func (m *model) togglePin(msg comm.Message) {
+	if m.isPending(msg.ID) {
+		m.notice = "cannot pin a message that is not sent yet"
+		return
+	}
+	if msg.ID == m.pinnedMessageID {
+		m.unpin()
+		m.notice = "unpinned"
+		return
+	}
+	m.pinnedMessageID = msg.ID
+	m.pinned = msg
+	m.msgPaneDirty = true
+	m.notice = "pinned; " + keys.describe(actPin) + " on it again unpins"
+	// The pane got a line shorter
+	m.scrollOffset = m.clampScrollOffset(m.scrollOffset)
+	m.ensureCursorVisible()
+}
+
+// unpin removes the pinned message line
+func (m *model) unpin() {
+	m.pinnedMessageID = ""
+	m.pinned = comm.Message{}
+	m.msgPaneDirty = true
+}
+
+// showingPin reports whether the pinned message line is shown: only in
+// the channel the message is in
+func (m model) showingPin() bool {
+	return m.pinnedMessageID != "" && m.current >= 0 && m.current < len(m.channels) &&
+		m.pinned.ChannelID == m.channels[m.current].ID
+}
+
+// pinnedMessage returns the pinned message as loaded, so edits show, or
+// as it was when pinned once it is scrolled out of what is loaded
+func (m model) pinnedMessage() comm.Message {
+	for _, msg := range m.messages {
+		if msg.ID == m.pinnedMessageID {
+			return msg
+		}
+	}
+	return m.pinned
+}
+
+// renderPinned renders the pinned message as one line
+func (m *model) renderPinned(mainWidth int) string {
+	msg := m.pinnedMessage()
+	label := style.current.Render("pinned")
+	text := strings.ReplaceAll(msg.Text, "\n", "↵")
+	line := " " + msg.CreatedAt.Local().Format(m.timeFormat) + " <" + m.nick(msg.SenderID) + "> " + text
+	return label + fitWidth(line, max(mainWidth-len("pinned"), 0))
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	comm "libcommunicator"
+)
+
+// The channel's pinned messages, as pinned on the server for everyone, are
+// listed in the search results view. Not to be confused with the one
+// message pinned above the pane here (pin.go).
+
+// pinnedPostsMsg carries the messages pinned in a channel
+type pinnedPostsMsg struct {
+	channelID string
+	posts     []comm.Message
+}
+
+// fetchPinnedPosts asks the server for the messages pinned in a channel,
+// newest first
+func fetchPinnedPosts(platform platformAPI, channelID string) tea.Cmd {
+	return func() tea.Msg {
+		posts, err := platform.GetPinnedMessages(channelID)
+		if err != nil {
+			return errMsg(&opError{op: "load pinned messages", err: err})
+		}
+		sortNewestFirst(posts)
+		return pinnedPostsMsg{channelID: channelID, posts: posts}
+	}
+}
+
+// showPinnedPosts lists the current channel's pinned messages, fetching
+// them the first time
+func (m *model) showPinnedPosts() tea.Cmd {
+	if m.current < 0 || m.current >= len(m.channels) {
+		return nil
+	}
+	channelID := m.channels[m.current].ID
+	posts, ok := m.pinnedPosts[channelID]
+	if !ok {
+		m.notice = "loading pinned messages…"
+		return fetchPinnedPosts(m.platform, channelID)
+	}
+	m.results = posts
+	m.resultsQuery = ""
+	m.resultsPinned = true
+	m.resultCursor = 0
+	m.showingResults = true
+	m.notice = ""
+	return nil
+}
+
+// handlePinnedPosts caches a channel's pinned messages and lists them if
+// the channel is still the current one
+func (m *model) handlePinnedPosts(msg pinnedPostsMsg) {
+	m.pinnedPosts[msg.channelID] = msg.posts
+	if m.current >= 0 && m.current < len(m.channels) && m.channels[m.current].ID == msg.channelID {
+		m.showPinnedPosts()
+	}
+}
+
+// pinnedCount returns "pinned 3" for the status bar once the current
+// channel's pinned messages are known, or ""
+func (m model) pinnedCount() string {
+	if m.current < 0 || m.current >= len(m.channels) {
+		return ""
+	}
+	posts, ok := m.pinnedPosts[m.channels[m.current].ID]
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("pinned %d", len(posts))
+}
+
+// pinnedHeader is the results view's first line for pinned messages
+func (m model) pinnedHeader() string {
+	switch len(m.results) {
+	case 0:
+		return "No pinned messages in this channel. Esc returns."
+	case 1:
+		return "1 pinned message. Enter opens it, Esc returns."
+	}
+	return fmt.Sprintf("%d pinned messages. Enter opens one, Esc returns.", len(m.results))
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	comm "libcommunicator"
+)
+
+// platformAPI is what the UI asks of the chat server. *comm.Platform
+// implements it; a fake can stand in for it to drive Update and View
+// without a server.
+type platformAPI interface {
+	SetTeamID(teamID string) error
+	GetTeams() ([]comm.Team, error)
+	GetChannels() ([]comm.Channel, error)
+	GetChannelMembers(channelID string) ([]comm.User, error)
+	GetChannelStats(channelID string) (*comm.ChannelStats, error)
+	MarkChannelRead(channelID string) error
+
+	GetMessages(channelID string, limit int) ([]comm.Message, error)
+	GetMessagesBefore(channelID, beforeID string, limit int) ([]comm.Message, error)
+	GetMessage(messageID string) (*comm.Message, error)
+	SearchMessages(teamID, query string) ([]comm.Message, error)
+	GetPinnedMessages(channelID string) ([]comm.Message, error)
+	SendMessage(channelID, text string) (*comm.Message, error)
+	SendReply(channelID, rootID, text string) (*comm.Message, error)
+	EditMessage(messageID, text string) (*comm.Message, error)
+	ExecuteCommand(channelID, command string) (string, error)
+	AddReaction(messageID, emojiName string) error
+	RemoveReaction(messageID, emojiName string) error
+	SendTyping(channelID string) error
+
+	GetUser(userID string) (*comm.User, error)
+	DownloadFile(fileID string) ([]byte, error)
+	GetUserStatus(userID string) (string, error)
+	SearchUsers(query string) ([]comm.User, error)
+
+	NewEventStream(ctx context.Context, bufferSize int, debounce time.Duration) (*comm.EventStream, error)
+}
+
+var _ platformAPI = (*comm.Platform)(nil)
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"termunicator/internal/atomicfile"
+)
+
+// prefs are the runtime-adjustable UI settings kept between launches.
+// They live in their own file next to (never inside) any credentials, and
+// explicit command-line flags always win over them.
+type prefs struct {
+	InlineReplies *bool `json:"inline_replies,omitempty"` // nil = show_replies in config.toml
+	HideSystem    bool  `json:"hide_system,omitempty"`    // joins, leaves...
+	SidebarWidth  int   `json:"sidebar_width,omitempty"`  // 0 = automatic
+}
+
+// prefsPath returns the location of the preferences file
+func prefsPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "termunicator", "prefs.json"), nil
+}
+
+// loadPrefs reads saved preferences. A missing file is not an error.
+func loadPrefs() (prefs, error) {
+	var p prefs
+	path, err := prefsPath()
+	if err != nil {
+		return p, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return p, nil
+	}
+	if err != nil {
+		return p, err
+	}
+	err = json.Unmarshal(data, &p)
+	return p, err
+}
+
+// savePrefs writes preferences atomically so a crash never leaves a
+// truncated file behind.
+func savePrefs(p prefs) error {
+	path, err := prefsPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, data, 0600)
+}
+
+// prefs returns the model's current preferences. Inline replies are only
+// saved when toggled away from config.toml's setting, so that a later
+// change to the file still takes effect.
+func (m model) prefs() prefs {
+	p := prefs{
+		HideSystem:   m.hideSystem,
+		SidebarWidth: m.sidebarWidth,
+	}
+	if m.showReplies != m.config.showReplies {
+		showReplies := m.showReplies
+		p.InlineReplies = &showReplies
+	}
+	return p
+}
+
+// savePrefsCmd persists preferences in the background
+func savePrefsCmd(p prefs) tea.Cmd {
+	return saveInBackground("savePrefs", func() error { return savePrefs(p) })
+}
@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestPrefsSaveOnlyOverride(t *testing.T) {
+	m := model{config: config{showReplies: true}, showReplies: true}
+	if p := m.prefs(); p.InlineReplies != nil {
+		t.Errorf("inline replies as in config.toml saved as %v", *p.InlineReplies)
+	}
+	m.showReplies = false
+	if p := m.prefs(); p.InlineReplies == nil || *p.InlineReplies {
+		t.Errorf("inline replies toggled off saved as %v, want false", show(p.InlineReplies))
+	}
+}
+
+func show(b *bool) string {
+	if b == nil {
+		return "unset"
+	}
+	if *b {
+		return "true"
+	}
+	return "false"
+}
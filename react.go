@@ -0,0 +1,303 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	comm "libcommunicator"
+)
+
+const (
+	pickerRows  = 8  // emoji shown at once in the picker
+	pickerWidth = 30 // columns, name and check mark included
+)
+
+// reactedMsg reports a reaction added or removed: the message as the
+// server now has it, or the error and the message as it was before
+type reactedMsg struct {
+	synced   *comm.Message
+	original comm.Message
+	err      error
+}
+
+// hasReacted reports whether userID reacted to msg with emoji
+func hasReacted(msg comm.Message, userID, emoji string) bool {
+	list, _ := postMetadata(msg, "reactions").([]interface{})
+	for _, item := range list {
+		r, ok := item.(map[string]interface{})
+		if ok && r["user_id"] == userID && r["emoji_name"] == emoji {
+			return true
+		}
+	}
+	return false
+}
+
+// withReaction returns msg with userID's emoji reaction added, or
+// removed. The metadata maps are copied, never changed in place, since
+// other copies of msg share them.
+func withReaction(msg comm.Message, userID, emoji string, add bool) comm.Message {
+	meta := copyMetadata(msg.Metadata)
+	holder := meta // where postMetadata finds the reactions
+	if _, top := meta["reactions"]; !top {
+		if nested, ok := meta["metadata"]; ok {
+			holder = copyMetadata(nested)
+			meta["metadata"] = holder
+		}
+	}
+
+	old, _ := holder["reactions"].([]interface{})
+	var list []interface{}
+	for _, item := range old {
+		r, ok := item.(map[string]interface{})
+		if ok && r["user_id"] == userID && r["emoji_name"] == emoji {
+			continue
+		}
+		list = append(list, item)
+	}
+	if add {
+		list = append(list, map[string]interface{}{
+			"user_id":    userID,
+			"post_id":    msg.ID,
+			"emoji_name": emoji,
+			"create_at":  float64(time.Now().UnixMilli()),
+		})
+	}
+	holder["reactions"] = list
+	msg.Metadata = meta
+	return msg
+}
+
+// copyMetadata returns a shallow copy of a metadata map, or an empty map
+func copyMetadata(v interface{}) map[string]interface{} {
+	c := make(map[string]interface{})
+	if old, ok := v.(map[string]interface{}); ok {
+		for k, v := range old {
+			c[k] = v
+		}
+	}
+	return c
+}
+
+// sendReaction adds or removes a reaction on the server, then fetches the
+// message so that the reaction line shows what the server has
+func sendReaction(platform platformAPI, original comm.Message, emoji string, add bool) tea.Cmd {
+	return func() tea.Msg {
+		var err error
+		if add {
+			err = platform.AddReaction(original.ID, emoji)
+		} else {
+			err = platform.RemoveReaction(original.ID, emoji)
+		}
+		if err != nil {
+			return reactedMsg{original: original, err: err}
+		}
+		synced, err := platform.GetMessage(original.ID)
+		if err != nil || synced == nil {
+			return nil // the optimistic line stands; events correct it
+		}
+		return reactedMsg{synced: synced, original: original}
+	}
+}
+
+// eventReaction returns the message, user and emoji of a reaction event.
+// Mattermost sends the reaction under "reaction", as an object or as its
+// JSON; some bindings flatten it into the data.
+func eventReaction(event *comm.Event) (messageID, userID, emoji string) {
+	data, _ := event.Data.(map[string]interface{})
+	r := data
+	switch v := data["reaction"].(type) {
+	case map[string]interface{}:
+		r = v
+	case string:
+		var decoded map[string]interface{}
+		if json.Unmarshal([]byte(v), &decoded) == nil {
+			r = decoded
+		}
+	}
+	messageID, _ = r["post_id"].(string)
+	userID, _ = r["user_id"].(string)
+	emoji, _ = r["emoji_name"].(string)
+	if messageID == "" {
+		messageID = event.MessageID
+	}
+	if userID == "" {
+		userID = event.UserID
+	}
+	return messageID, userID, emoji
+}
+
+// applyReaction adds or removes the reaction an event reports on the
+// loaded message. Our own come back too, which the optimistic change
+// already shows; withReaction makes applying one twice harmless.
+func (m *model) applyReaction(event *comm.Event, add bool) {
+	messageID, userID, emoji := eventReaction(event)
+	if messageID == "" || userID == "" || emoji == "" {
+		log.Printf("reaction event without message, user or emoji: %v", event.Data)
+		return
+	}
+	for _, msg := range m.messages {
+		if msg.ID == messageID {
+			m.replaceMessage(withReaction(msg, userID, emoji, add))
+			m.nick(userID) // resolve now, for showing who reacted
+			return
+		}
+	}
+}
+
+// replaceMessage puts msg in place of the loaded message with its ID,
+// keeping indices, scroll position and cursor
+func (m *model) replaceMessage(msg comm.Message) {
+	for i := range m.messages {
+		if m.messages[i].ID == msg.ID {
+			m.messages[i] = msg
+			m.displayMsgsDirty = true
+			m.scrollOffset = m.clampScrollOffset(m.scrollOffset)
+			return
+		}
+	}
+}
+
+// handleReacted reconciles the reaction line with the server's, or
+// restores it if the reaction failed
+func (m *model) handleReacted(msg reactedMsg) {
+	if msg.err != nil {
+		m.replaceMessage(msg.original)
+		m.reportError("react", msg.err)
+		return
+	}
+	m.replaceMessage(*msg.synced)
+}
+
+// openPicker opens the emoji picker to react to msg
+func (m *model) openPicker(msg comm.Message) {
+	if m.isPending(msg.ID) {
+		m.notice = "cannot react to a message that is not sent yet"
+		return
+	}
+	m.picking = true
+	m.pickTarget = msg
+	m.pickFilter = ""
+	m.pickCursor = 0
+}
+
+// pickerChoices returns the emoji names the picker offers for its filter:
+// those already on the message first, then the shortcode table by name
+func (m model) pickerChoices() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, r := range reactions(m.pickTarget) {
+		seen[r.emoji] = true
+		names = append(names, r.emoji)
+	}
+	var table []string
+	for name := range emojiShortcodes {
+		if !seen[name] {
+			table = append(table, name)
+		}
+	}
+	sort.Strings(table)
+	names = append(names, table...)
+
+	var choices []string
+	for _, name := range names {
+		if strings.Contains(name, m.pickFilter) {
+			choices = append(choices, name)
+		}
+	}
+	return choices
+}
+
+// pick reacts with emoji, or takes back the reaction if it is ours
+func (m *model) pick(emoji string) tea.Cmd {
+	m.picking = false
+	original := m.pickTarget
+	for _, msg := range m.messages {
+		if msg.ID == original.ID {
+			original = msg // with what changed while picking
+		}
+	}
+	add := !hasReacted(original, m.myUserID, emoji)
+	m.replaceMessage(withReaction(original, m.myUserID, emoji, add))
+	return sendReaction(m.platform, original, emoji, add)
+}
+
+// handlePickerKeys handles keys while the emoji picker is open: typing
+// narrows it, enter reacts and esc closes it
+func (m model) handlePickerKeys(key string) (tea.Model, tea.Cmd, bool) {
+	if !m.picking {
+		return m, nil, false
+	}
+	switch keys.action(ctxGeneral, key) {
+	case actQuit:
+		return m, nil, false // let the global handler quit
+	case actCancel:
+		m.picking = false
+		return m, nil, true
+	}
+	choices := m.pickerChoices()
+	switch keys.action(ctxPrompt, key) {
+	case actListUp:
+		m.pickCursor = max(m.pickCursor-1, 0)
+		return m, nil, true
+	case actListDown:
+		m.pickCursor = max(min(m.pickCursor+1, len(choices)-1), 0)
+		return m, nil, true
+	case actAccept:
+		switch {
+		case len(choices) > 0:
+			return m, m.pick(choices[min(m.pickCursor, len(choices)-1)]), true
+		case m.pickFilter != "":
+			return m, m.pick(m.pickFilter), true // a custom emoji, by name
+		}
+		return m, nil, true
+	}
+	switch {
+	case keys.action(ctxInput, key) == actDeleteBack:
+		if r := []rune(m.pickFilter); len(r) > 0 {
+			m.pickFilter = string(r[:len(r)-1])
+			m.pickCursor = 0
+		}
+	case printableKey(key) && key != " ":
+		m.pickFilter += key
+		m.pickCursor = 0
+	}
+	return m, nil, true
+}
+
+// renderPicker renders the emoji picker box: the filter, then a window of
+// choices around the cursor, ours checked
+func (m model) renderPicker(mainWidth int) string {
+	width := min(pickerWidth, mainWidth)
+	row := func(text string, st lipgloss.Style) string {
+		text = fitWidth(text, width)
+		return st.Render(text + strings.Repeat(" ", max(width-lipgloss.Width(text), 0)))
+	}
+	lines := []string{row(" react: "+m.pickFilter+"_", style.status)}
+
+	choices := m.pickerChoices()
+	if len(choices) == 0 {
+		lines = append(lines, row(" Enter reacts with :"+m.pickFilter+":", style.status))
+	}
+	start := max(min(m.pickCursor-pickerRows/2, len(choices)-pickerRows), 0)
+	for i := start; i < len(choices) && i < start+pickerRows; i++ {
+		name := choices[i]
+		text := " " + emojiGlyph(name)
+		if _, ok := emojiShortcodes[name]; ok {
+			text += " " + name
+		}
+		if hasReacted(m.pickTarget, m.myUserID, name) {
+			text += " ✓"
+		}
+		st := style.status
+		if i == m.pickCursor {
+			st = style.highlighted
+		}
+		lines = append(lines, row(text, st))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	comm "libcommunicator"
+)
+
+// Reconnect backoff - Pike/Cox: named constants instead of magic numbers
+const (
+	reconnectBaseDelay   = time.Second
+	reconnectMaxDelay    = 30 * time.Second
+	maxReconnectAttempts = 8 // then wait for ctrl+r
+)
+
+// streamLostMsg reports that the event stream stopped delivering events
+type streamLostMsg struct {
+	stream *comm.EventStream
+	err    error
+}
+
+// reconnectMsg fires when it is time for the next reconnect attempt
+type reconnectMsg struct{}
+
+// streamRestoredMsg carries the new event stream after a reconnect
+type streamRestoredMsg struct {
+	stream *comm.EventStream
+}
+
+// streamFailedMsg reports a failed reconnect attempt
+type streamFailedMsg struct {
+	err error
+}
+
+// connectionLost reports whether a connection state event says the
+// websocket went down. The state is a string, either as the event data or
+// under "state" in it.
+func connectionLost(event *comm.Event) bool {
+	state, _ := event.Data.(string)
+	if data, ok := event.Data.(map[string]interface{}); ok {
+		state, _ = data["state"].(string)
+	}
+	state = strings.ToLower(state)
+	for _, word := range []string{"disconnect", "closed", "failed", "error"} {
+		if strings.Contains(state, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// reconnectDelay returns the backoff before attempt n (0-based):
+// 1s, 2s, 4s... capped at reconnectMaxDelay
+func reconnectDelay(n int) time.Duration {
+	delay := reconnectBaseDelay
+	for i := 0; i < n && delay < reconnectMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > reconnectMaxDelay {
+		return reconnectMaxDelay
+	}
+	return delay
+}
+
+// streamLost drops the dead event stream and schedules the first
+// reconnect attempt. Losses reported while already reconnecting are
+// ignored.
+func (m *model) streamLost(err error) tea.Cmd {
+	if m.reconnecting {
+		return nil
+	}
+	log.Printf("event stream lost: %v", err)
+	if m.eventStream != nil {
+		m.eventStream.Close()
+		m.eventStream = nil
+	}
+	m.reconnecting = true
+	m.reconnectAttempt = 0
+	return m.scheduleReconnect()
+}
+
+// scheduleReconnect waits out the backoff for the next attempt
+func (m *model) scheduleReconnect() tea.Cmd {
+	delay := reconnectDelay(m.reconnectAttempt)
+	m.notice = fmt.Sprintf("reconnecting… (attempt %d/%d in %s)", m.reconnectAttempt+1, maxReconnectAttempts, delay)
+	return tea.Tick(delay, func(time.Time) tea.Msg { return reconnectMsg{} })
+}
+
+// reconnect opens a new event stream on the existing platform session
+func (m model) reconnect() tea.Cmd {
+	platform, ctx := m.platform, m.ctx
+	return func() tea.Msg {
+		stream, err := platform.NewEventStream(ctx, eventStreamBufferSize, eventStreamDebounceDelay)
+		if err != nil {
+			return streamFailedMsg{err: err}
+		}
+		return streamRestoredMsg{stream: stream}
+	}
+}
+
+// handleReconnect handles the reconnect messages, reporting whether msg
+// was one of them
+func (m model) handleReconnect(msg tea.Msg) (tea.Model, tea.Cmd, bool) {
+	switch msg := msg.(type) {
+	case streamLostMsg:
+		// A waiter on an old stream may report after we replaced it
+		if msg.stream != m.eventStream {
+			return m, nil, true
+		}
+		return m, m.streamLost(msg.err), true
+
+	case reconnectMsg:
+		if !m.reconnecting || m.platform == nil {
+			return m, nil, true
+		}
+		m.notice = fmt.Sprintf("reconnecting… (attempt %d/%d)", m.reconnectAttempt+1, maxReconnectAttempts)
+		return m, m.reconnect(), true
+
+	case streamFailedMsg:
+		log.Printf("reconnect attempt %d: %v", m.reconnectAttempt+1, msg.err)
+		m.reconnectAttempt++
+		if m.reconnectAttempt >= maxReconnectAttempts {
+			m.showError(&opError{op: "reconnect", err: msg.err})
+			m.notice = "connection lost; press " + keys.describe(actReconnect) + " to retry"
+			return m, nil, true
+		}
+		return m, m.scheduleReconnect(), true
+
+	case streamRestoredMsg:
+		m.eventStream = msg.stream
+		m.reconnecting = false
+		m.reconnectAttempt = 0
+		m.notice = "reconnected"
+		cmds := []tea.Cmd{waitForEvent(m.eventStream)}
+		// Catch up on what was missed while disconnected
+		if m.current >= 0 && m.current < len(m.channels) {
+			cmds = append(cmds, fetchMessages(m.platform, m.channels[m.current].ID))
+		}
+		return m, tea.Batch(cmds...), true
+	}
+	return m, nil, false
+}
+
+// retryConnection restarts reconnecting at once, for ctrl+r after the
+// automatic attempts gave up
+func (m *model) retryConnection() tea.Cmd {
+	if !m.connected || (m.reconnecting && m.reconnectAttempt < maxReconnectAttempts) {
+		return nil
+	}
+	if m.eventStream != nil {
+		m.eventStream.Close()
+		m.eventStream = nil
+	}
+	m.reconnecting = true
+	m.reconnectAttempt = 0
+	m.notice = "reconnecting…"
+	return m.reconnect()
+}
+
+// connDotWidth is the width of the connection state dot in the status bar
+const connDotWidth = 2
+
+// Connection state colors for the status bar dot
+var (
+	connHealthy      = lipgloss.Color("10") // green
+	connReconnecting = lipgloss.Color("11") // yellow
+	connDown         = lipgloss.Color("9")  // red
+)
+
+// connColor returns the status bar dot color for the event stream: down
+// once the reconnect attempts gave up, or with no stream at all
+func (m model) connColor() lipgloss.Color {
+	switch {
+	case m.reconnecting && m.reconnectAttempt >= maxReconnectAttempts:
+		return connDown
+	case m.reconnecting:
+		return connReconnecting
+	case m.eventStream == nil:
+		return connDown
+	}
+	return connHealthy
+}
+
+// lastEventStatus says when the last event arrived, so that a stream that
+// stalled without failing shows in the status bar
+func (m model) lastEventStatus() string {
+	if m.lastEvent.IsZero() {
+		return "no events yet"
+	}
+	return "last event " + m.lastEvent.Format(m.timeFormat)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+
+	comm "libcommunicator"
+)
+
+// Quotes are cut to this much of the message, so that the reply, not the
+// quote, fills the input
+const (
+	maxQuoteLines = 3
+	maxQuoteRunes = 200
+)
+
+// quoteText returns text as a Markdown quote, "> line" per line, cut to
+// maxQuoteLines and maxQuoteRunes with an ellipsis, and a line break after
+// it for the reply
+func quoteText(text string) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	cut := false
+	if len(lines) > maxQuoteLines {
+		lines, cut = lines[:maxQuoteLines], true
+	}
+	var quoted []string
+	left := maxQuoteRunes
+	for _, line := range lines {
+		if left <= 0 {
+			cut = true
+			break
+		}
+		runes := []rune(line)
+		if len(runes) > left {
+			runes, cut = runes[:left], true
+		}
+		left -= len(runes)
+		quoted = append(quoted, "> "+string(runes))
+	}
+	s := strings.Join(quoted, "\n")
+	if cut {
+		s += "…"
+	}
+	return s + "\n"
+}
+
+// reply answers the selected message: in the channel it opens the
+// message's thread, so what is sent next is a thread reply; in a thread,
+// which already has its root, it quotes the message into the input
+func (m *model) reply(msg comm.Message) {
+	if m.isPending(msg.ID) {
+		m.notice = "cannot reply to a message that is not sent yet"
+		return
+	}
+	if m.threadRootID == "" {
+		rootID := m.replyRoot(msg)
+		if rootID == "" {
+			rootID = msg.ID
+		}
+		m.enterThread(rootID)
+		m.notice = "replying in thread; Esc returns"
+		return
+	}
+	quote := quoteText(msg.Text)
+	runes := []rune(m.input)
+	m.input = string(runes[:m.cursorPos]) + quote + string(runes[m.cursorPos:])
+	m.cursorPos += len([]rune(quote))
+	m.historyIndex = -1
+	m.messageCursor = -1 // type the reply
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// handleSearchKeys handles the search key (ctrl+f), which opens the search
+// prompt, the prompt itself, and next/prev match (n/N), which step to the
+// next older/newer match
+func (m model) handleSearchKeys(key string) (tea.Model, tea.Cmd, bool) {
+	if !m.mainFocused() {
+		return m, nil, false
+	}
+	if !m.searching {
+		if keys.action(ctxMain, key) == actSearch {
+			m.searching = true
+			m.setSearchQuery("")
+			return m, nil, true
+		}
+		switch act := keys.action(ctxMessage, key); {
+		case act == actNextMatch && m.searchQuery != "" && m.messageCursor >= 0:
+			return m, m.searchFrom(m.messageCursor-1, -1), true
+		case act == actPrevMatch && m.searchQuery != "" && m.messageCursor >= 0:
+			return m, m.searchFrom(m.messageCursor+1, 1), true
+		}
+		return m, nil, false
+	}
+
+	// The prompt takes every key until enter or esc
+	switch {
+	case keys.action(ctxGeneral, key) == actCancel:
+		m.searching = false
+		m.setSearchQuery("")
+	case keys.action(ctxPrompt, key) == actAccept:
+		m.searching = false
+		if m.searchQuery == "" {
+			return m, nil, true
+		}
+		// From the selected message, or else the newest one shown
+		start := m.messageCursor
+		if start < 0 {
+			start = len(m.getDisplayMessages()) - 1 - m.scrollOffset
+		}
+		return m, m.searchFrom(start, -1), true
+	case keys.action(ctxInput, key) == actDeleteBack:
+		runes := []rune(m.searchQuery)
+		if len(runes) > 0 {
+			m.setSearchQuery(string(runes[:len(runes)-1]))
+		}
+	case printableKey(key):
+		m.setSearchQuery(m.searchQuery + key)
+	}
+	return m, nil, true
+}
+
+// setSearchQuery changes the query, and so the highlighted matches
+func (m *model) setSearchQuery(query string) {
+	m.searchQuery = query
+	m.searchOlder = false
+	m.msgPaneDirty = true
+}
+
+// findMatch selects the first message matching the query from display
+// index i on, stepping by dir, and reports whether there was one
+func (m *model) findMatch(i, dir int) bool {
+	display := m.getDisplayMessages()
+	for ; i >= 0 && i < len(display); i += dir {
+		if start, _ := indexFold(display[i].Text, m.searchQuery); start >= 0 {
+			m.messageCursor = i
+			m.ensureCursorVisible()
+			return true
+		}
+	}
+	return false
+}
+
+// searchFrom selects the next match from display index i on, stepping by
+// dir. Searching back past the oldest loaded message fetches older ones;
+// handleSearchResults carries on once they arrive.
+func (m *model) searchFrom(i, dir int) tea.Cmd {
+	if m.findMatch(i, dir) {
+		return nil
+	}
+	if dir < 0 && m.threadRootID == "" && len(m.messages) > 0 && m.current >= 0 && m.current < len(m.channels) {
+		m.searchOlder = true
+		m.searchAnchor = ""
+		if display := m.getDisplayMessages(); len(display) > 0 {
+			m.searchAnchor = display[0].ID
+		}
+		m.notice = fmt.Sprintf("searching older messages for %q…", m.searchQuery)
+		return m.loadOlder()
+	}
+	m.notice = fmt.Sprintf("no more matches for %q", m.searchQuery)
+	return nil
+}
+
+// handleSearchResults goes on with a search once the older messages it
+// asked for arrive, reporting whether msg was them
+func (m model) handleSearchResults(msg tea.Msg) (tea.Model, tea.Cmd, bool) {
+	older, ok := msg.(olderMessagesMsg)
+	if !ok || !m.searchOlder {
+		return m, nil, false
+	}
+	m.searchOlder = false
+	next, cmd := m.update(msg) // prepend them as usual
+	nm := next.(model)
+	if len(older) == 0 {
+		nm.notice = fmt.Sprintf("no more matches for %q", nm.searchQuery)
+		return nm, cmd, true
+	}
+
+	// Search what was added above the oldest message searched so far
+	display := nm.getDisplayMessages()
+	start := len(display) - 1
+	for i, dm := range display {
+		if dm.ID == m.searchAnchor {
+			start = i - 1
+			break
+		}
+	}
+	if cmd != nil {
+		// Nothing to show came back and more is already being fetched
+		if !nm.findMatch(start, -1) {
+			nm.searchOlder = true
+		}
+		return nm, cmd, true
+	}
+	if nm.findMatch(start, -1) {
+		nm.notice = ""
+		return nm, nil, true
+	}
+	return nm, nm.searchFrom(start, -1), true
+}
+
+// indexFold returns the byte range of the first case-insensitive match of
+// substr in s, or -1, -1
+func indexFold(s, substr string) (int, int) {
+	if substr == "" {
+		return -1, -1
+	}
+	for i := range s {
+		if n, ok := prefixFold(s[i:], substr); ok {
+			return i, i + n
+		}
+	}
+	return -1, -1
+}
+
+// prefixFold reports whether s starts with prefix ignoring case, and how
+// many bytes of s the match takes
+func prefixFold(s, prefix string) (int, bool) {
+	n := 0
+	for _, pr := range prefix {
+		if n >= len(s) {
+			return 0, false
+		}
+		r, size := utf8.DecodeRuneInString(s[n:])
+		if r != pr && !strings.EqualFold(string(r), string(pr)) {
+			return 0, false
+		}
+		n += size
+	}
+	return n, true
+}
+
+// markMatches splits the matches of query out of spans so they can be
+// highlighted
+func markMatches(spans []span, query string) []span {
+	if query == "" {
+		return spans
+	}
+	var out []span
+	for _, sp := range spans {
+		rest := sp.text
+		for {
+			start, end := indexFold(rest, query)
+			if start < 0 {
+				break
+			}
+			if start > 0 {
+				before := sp
+				before.text = rest[:start]
+				out = append(out, before)
+			}
+			match := sp
+			match.text = rest[start:end]
+			match.match = true
+			out = append(out, match)
+			rest = rest[end:]
+		}
+		if rest != "" {
+			after := sp
+			after.text = rest
+			out = append(out, after)
+		}
+	}
+	return out
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	comm "libcommunicator"
+)
+
+// searchResultsMsg carries the server's matches for a /search query
+type searchResultsMsg struct {
+	query   string
+	results []comm.Message
+}
+
+// searchCommand reports whether input is "/search QUERY", and the query.
+// It runs here rather than on the server like other slash commands.
+func searchCommand(input string) (string, bool) {
+	fields := strings.Fields(input)
+	if len(fields) < 2 || fields[0] != "/search" {
+		return "", false
+	}
+	return strings.Join(fields[1:], " "), true
+}
+
+// searchMessages asks the server for the messages in a team matching
+// query, newest first
+func searchMessages(platform platformAPI, teamID, query string) tea.Cmd {
+	return func() tea.Msg {
+		results, err := platform.SearchMessages(teamID, query)
+		if err != nil {
+			return errMsg(&opError{op: "search", err: err})
+		}
+		sortNewestFirst(results)
+		return searchResultsMsg{query: query, results: results}
+	}
+}
+
+func sortNewestFirst(msgs []comm.Message) {
+	sort.SliceStable(msgs, func(i, j int) bool { return msgs[i].CreatedAt.After(msgs[j].CreatedAt) })
+}
+
+// startSearch sends a server search for the current team
+func (m *model) startSearch(query string) tea.Cmd {
+	if !m.teamSelected || m.currentTeam < 0 || m.currentTeam >= len(m.teams) {
+		m.notice = "select a team to search"
+		return nil
+	}
+	m.notice = fmt.Sprintf("searching for %q…", query)
+	return searchMessages(m.platform, m.teams[m.currentTeam].ID, query)
+}
+
+// showResults replaces the message pane with search results
+func (m *model) showResults(msg searchResultsMsg) {
+	m.resultsQuery = msg.query
+	m.resultsPinned = false
+	m.results = msg.results
+	m.resultCursor = 0
+	m.showingResults = true
+	m.notice = ""
+}
+
+// handleResultKeys moves through the search results; enter opens the
+// selected one in its channel and esc closes them
+func (m model) handleResultKeys(key string) (tea.Model, tea.Cmd, bool) {
+	if !m.showingResults || !m.mainFocused() {
+		return m, nil, false
+	}
+	if keys.action(ctxGeneral, key) == actCancel {
+		m.showingResults = false
+		return m, nil, true
+	}
+	last := len(m.results) - 1
+	page := max(m.msgHeight()-1, 1)
+	switch keys.action(ctxPrompt, key) {
+	case actListUp:
+		m.resultCursor = max(m.resultCursor-1, 0)
+	case actListDown:
+		m.resultCursor = max(min(m.resultCursor+1, last), 0)
+	case actListPageUp:
+		m.resultCursor = max(m.resultCursor-page, 0)
+	case actListPageDown:
+		m.resultCursor = max(min(m.resultCursor+page, last), 0)
+	case actAccept:
+		if m.input != "" || len(m.results) == 0 {
+			return m, nil, false // send what was typed
+		}
+		newModel, cmd := m.openResult(m.results[m.resultCursor])
+		return newModel, cmd, true
+	default:
+		return m, nil, false
+	}
+	return m, nil, true
+}
+
+// openResult switches to the channel of a search result and selects it
+// there, fetching older messages until it is loaded (see handleJump)
+func (m model) openResult(target comm.Message) (tea.Model, tea.Cmd) {
+	m.showingResults = false
+	m.searchOlder = false
+	for i, ch := range m.channels {
+		if ch.ID != target.ChannelID {
+			continue
+		}
+		m.jumpTarget = &target
+		if i == m.current {
+			if m.threadRootID != "" {
+				m.exitThread()
+			}
+			return m, m.continueJump(target, false)
+		}
+		m.selected = i
+		m.selectedType = navChannel
+		if isDM(ch) {
+			m.selectedType = navDM
+		}
+		m.focus = focusSidebar
+		return m.openSelected()
+	}
+	m.notice = "that message is in a channel not in the sidebar"
+	return m, nil
+}
+
+// handleJump goes on looking for the search result being opened as
+// messages for its channel arrive, reporting whether msg was handled
+func (m model) handleJump(msg tea.Msg) (tea.Model, tea.Cmd, bool) {
+	if m.jumpTarget == nil {
+		return m, nil, false
+	}
+	switch msg.(type) {
+	case messagesMsg, olderMessagesMsg:
+	default:
+		return m, nil, false
+	}
+	target := *m.jumpTarget
+	m.jumpTarget = nil
+	next, cmd := m.update(msg) // load them as usual
+	nm := next.(model)
+	if nm.current < 0 || nm.current >= len(nm.channels) || nm.channels[nm.current].ID != target.ChannelID {
+		return nm, cmd, true // moved on to another channel
+	}
+	if cmd != nil && !nm.hasMessage(target.ID) {
+		// Only replies came back and more is already being fetched
+		nm.jumpTarget = &target
+		return nm, cmd, true
+	}
+	older, ok := msg.(olderMessagesMsg)
+	return nm, tea.Batch(cmd, nm.continueJump(target, ok && len(older) == 0)), true
+}
+
+// continueJump selects target if it is loaded, and otherwise fetches
+// older messages while there may be some that reach back to it. exhausted
+// means the server has no older ones.
+func (m *model) continueJump(target comm.Message, exhausted bool) tea.Cmd {
+	if m.selectMessage(target) {
+		m.jumpTarget = nil
+		m.notice = ""
+		return nil
+	}
+	if exhausted || len(m.messages) == 0 || !m.messages[0].CreatedAt.After(target.CreatedAt) {
+		m.jumpTarget = nil
+		m.notice = "could not find that message (deleted?)"
+		return nil
+	}
+	m.jumpTarget = &target
+	m.notice = "loading older messages…"
+	return m.loadOlder()
+}
+
+// selectMessage selects target if it is loaded, opening its thread if it
+// is a reply hidden from the channel view
+func (m *model) selectMessage(target comm.Message) bool {
+	if !m.hasMessage(target.ID) {
+		return false
+	}
+	find := func() bool {
+		for i, msg := range m.getDisplayMessages() {
+			if msg.ID == target.ID {
+				m.messageCursor = i
+				m.ensureCursorVisible()
+				return true
+			}
+		}
+		return false
+	}
+	if find() {
+		return true
+	}
+	if rootID := m.replyRoot(target); rootID != "" {
+		m.enterThread(rootID)
+	}
+	return find()
+}
+
+// renderResults renders the search results in place of the messages,
+// msgHeight lines like renderMessages
+func (m model) renderResults(mainWidth, msgHeight int) string {
+	var lines []string
+	switch {
+	case m.resultsPinned:
+		lines = append(lines, m.pinnedHeader())
+	case len(m.results) == 0:
+		lines = append(lines, fmt.Sprintf("No messages match %q. Esc returns.", m.resultsQuery))
+	case len(m.results) == 1:
+		lines = append(lines, fmt.Sprintf("1 message matches %q. Enter opens it, Esc returns.", m.resultsQuery))
+	default:
+		lines = append(lines, fmt.Sprintf("%d messages match %q. Enter opens one, Esc returns.", len(m.results), m.resultsQuery))
+	}
+	lines[0] = style.status.Render(padRight(fitWidth(lines[0], mainWidth), mainWidth))
+
+	// Keep the cursor in the window of rows below the header
+	rows := msgHeight - 1
+	start := max(min(m.resultCursor-rows/2, len(m.results)-rows), 0)
+	for i := start; i < len(m.results) && i < start+rows; i++ {
+		lines = append(lines, m.renderResult(m.results[i], i == m.resultCursor, mainWidth))
+	}
+	for len(lines) < msgHeight {
+		lines = append(lines, "")
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// renderResult formats one result: date and time, channel, sender and
+// the first line of the text
+func (m model) renderResult(msg comm.Message, selected bool, mainWidth int) string {
+	channel := msg.ChannelID
+	for _, ch := range m.channels {
+		if ch.ID == msg.ChannelID {
+			channel = channelName(ch)
+			break
+		}
+	}
+	when := msg.CreatedAt.Local().Format("Jan 2 " + m.timeFormat)
+	text := strings.ReplaceAll(msg.Text, "\n", " ")
+	line := fitWidth(fmt.Sprintf("%s %s <%s> %s", when, channel, m.nick(msg.SenderID), text), mainWidth)
+	if selected {
+		return style.highlighted.Render(padRight(line, mainWidth))
+	}
+	return style.time.Render(when) + strings.TrimPrefix(line, when)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	comm "libcommunicator"
+
+	"termunicator/internal/state"
+)
+
+// shutdownTimeout bounds how long quitting waits for the server to
+// acknowledge; a dead socket must not keep the terminal hostage
+const shutdownTimeout = 3 * time.Second
+
+// signalMsg reports SIGTERM, SIGHUP or SIGINT, which quit like ctrl+c
+type signalMsg struct {
+	sig os.Signal
+}
+
+// notifySignals forwards termination signals to p, so closing the
+// terminal or kill(1) shut down as cleanly as ctrl+c
+func notifySignals(p *tea.Program) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, syscall.SIGHUP, os.Interrupt)
+	go func() {
+		for sig := range sigs {
+			p.Send(signalMsg{sig: sig})
+		}
+	}()
+}
+
+// quit starts shutting down: read positions are recorded and everything
+// else happens off the UI goroutine. Asked a second time while that is
+// under way, it quits at once.
+func (m *model) quit() tea.Cmd {
+	if m.quitting {
+		return tea.Quit
+	}
+	m.quitting = true
+	m.notice = "quitting…"
+	m.recordRead()
+	m.cancel()
+	var snapshot *state.State
+	if m.readState != nil {
+		snapshot = m.readState.Copy()
+	}
+	return shutdown(snapshot, m.eventStream, m.closePlatform)
+}
+
+// shutdown saves the read positions, closes the event stream and logs
+// out, giving up on the server after shutdownTimeout. The library is
+// released and the program quits either way.
+func shutdown(s *state.State, stream *comm.EventStream, closePlatform func()) tea.Cmd {
+	return func() tea.Msg {
+		if s != nil {
+			if err := s.Save(); err != nil {
+				log.Printf("state.Save: %v", err)
+			}
+		}
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			if stream != nil {
+				stream.Close()
+			}
+			if closePlatform != nil {
+				closePlatform()
+			}
+		}()
+		select {
+		case <-done:
+		case <-time.After(shutdownTimeout):
+			log.Printf("shutdown: server did not answer within %s; quitting anyway", shutdownTimeout)
+		}
+		comm.Cleanup()
+		return tea.Quit()
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	comm "libcommunicator"
+)
+
+// systemPostPrefix starts the post type of messages the server posts
+// itself: joins, leaves, header changes...
+const systemPostPrefix = "system_"
+
+// isSystemMessage reports whether the server posted msg rather than a user
+func isSystemMessage(msg comm.Message) bool {
+	postType, _ := postMetadata(msg, "type").(string)
+	return strings.HasPrefix(postType, systemPostPrefix)
+}
+
+// renderSystemMessage renders a system message as one dim, italic line
+// centered in the pane, "-- alice joined the channel --", with no nick
+func (m model) renderSystemMessage(msg comm.Message, isHighlighted bool, mainWidth int) []string {
+	text := strings.Join(strings.Fields(msg.Text), " ")
+	text = strings.TrimSuffix(text, ".")
+	line := fitWidth("-- "+text+" --", mainWidth)
+	line = strings.Repeat(" ", max((mainWidth-lipgloss.Width(line))/2, 0)) + line
+	if isHighlighted {
+		return []string{style.highlighted.Render(padRight(line, mainWidth))}
+	}
+	return []string{style.time.Italic(true).Render(line)}
+}
+
+// toggleSystem hides or shows system messages, keeping the message cursor
+// on the same message
+func (m *model) toggleSystem() {
+	cursorID := m.cursorMessageID()
+	m.hideSystem = !m.hideSystem
+	m.displayMsgsDirty = true
+	m.reselect(cursorID)
+	if m.hideSystem {
+		m.notice = "system messages hidden"
+	} else {
+		m.notice = "system messages shown"
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+
+	tea "github.com/charmbracelet/bubbletea"
+	comm "libcommunicator"
+)
+
+// channelsLoadedMsg carries the channels of the team switched to, or why
+// switching failed
+type channelsLoadedMsg struct {
+	teamID   string
+	channels []comm.Channel
+	err      error
+}
+
+// teamSwitch serializes the calls that depend on the platform's current
+// team, of which there is one: a switch's SetTeamID and GetChannels must
+// not interleave with another switch or a channel refresh. wanted is the
+// team asked for last; a switch superseded before it starts is skipped.
+var teamSwitch struct {
+	sync.Mutex
+	wanted atomic.Value // string
+}
+
+// loadTeamChannels switches the platform to teamID and fetches its
+// channels without blocking the UI
+func loadTeamChannels(platform platformAPI, teamID string) tea.Cmd {
+	teamSwitch.wanted.Store(teamID)
+	return func() tea.Msg {
+		teamSwitch.Lock()
+		defer teamSwitch.Unlock()
+		if teamSwitch.wanted.Load() != teamID {
+			return nil // switched again before this one started
+		}
+		if err := platform.SetTeamID(teamID); err != nil {
+			return channelsLoadedMsg{teamID: teamID, err: &opError{op: "switch team", err: err}}
+		}
+		channels, err := platform.GetChannels()
+		if err != nil {
+			return channelsLoadedMsg{teamID: teamID, err: &opError{op: "load channels", err: err}}
+		}
+		return channelsLoadedMsg{teamID: teamID, channels: channels}
+	}
+}
+
+// handleChannelsLoaded shows the channels of the team switched to, unless
+// the user has switched to another team since
+func (m *model) handleChannelsLoaded(msg channelsLoadedMsg) tea.Cmd {
+	if msg.teamID != m.loadingTeam {
+		log.Printf("dropping channels of team %s: switched to %q since", msg.teamID, m.loadingTeam)
+		return nil
+	}
+	m.loadingTeam = ""
+	m.notice = ""
+	m.msgPaneDirty = true
+	if msg.err != nil {
+		log.Printf("error: %v", msg.err)
+		m.showError(msg.err)
+		return nil
+	}
+	m.channels = msg.channels
+	m.current = -1
+	m.navItemsDirty = true
+	// Move cursor to first channel if available
+	for _, item := range m.getNavItems() {
+		if item.itemType == navChannel || item.itemType == navDM {
+			m.selected = item.index
+			m.selectedType = item.itemType
+			break
+		}
+	}
+	if len(msg.channels) == 0 {
+		// Not an error: the message pane says so and the cursor stays
+		// on the teams
+		log.Printf("GetChannels: no channels in team %s", msg.teamID)
+	}
+	return m.nameGroups()
+}
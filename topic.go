@@ -0,0 +1,84 @@
+package main
+
+import (
+	"log"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	comm "libcommunicator"
+)
+
+// userStatusMsg carries a user's presence: online, away, dnd or offline
+type userStatusMsg struct {
+	userID string
+	status string
+}
+
+// dmPartner returns the other user in a direct message channel, whose
+// name is the two user IDs joined by "__", or "" for any other channel
+func (m model) dmPartner(ch comm.Channel) string {
+	if ch.Type != comm.ChannelTypeDirectMessage {
+		return ""
+	}
+	ids := strings.SplitN(ch.Name, "__", 2)
+	if len(ids) != 2 {
+		return ""
+	}
+	if ids[0] == m.myUserID {
+		return ids[1]
+	}
+	return ids[0]
+}
+
+// fetchUserStatus asks for a user's presence. It is only decoration, so
+// failures are logged.
+func fetchUserStatus(platform platformAPI, userID string) tea.Cmd {
+	return func() tea.Msg {
+		status, err := platform.GetUserStatus(userID)
+		if err != nil {
+			log.Printf("get status of %s: %v", userID, err)
+			return nil
+		}
+		return userStatusMsg{userID: userID, status: status}
+	}
+}
+
+// eventStatus returns the new status in a status change event: the event
+// data, or "status" in it
+func eventStatus(event *comm.Event) string {
+	status, _ := event.Data.(string)
+	if data, ok := event.Data.(map[string]interface{}); ok {
+		status, _ = data["status"].(string)
+	}
+	return status
+}
+
+// setStatus records a user's presence
+func (m *model) setStatus(userID, status string) {
+	if userID == "" || status == "" {
+		return
+	}
+	if m.statuses == nil {
+		m.statuses = make(map[string]string)
+	}
+	m.statuses[userID] = status
+}
+
+// renderTopic renders the bar above the messages: the channel's name and
+// header, or for a direct message the other user and their status
+func (m model) renderTopic(mainWidth int) string {
+	text := ""
+	if m.current >= 0 && m.current < len(m.channels) {
+		ch := m.channels[m.current]
+		text = channelName(ch)
+		if partner := m.dmPartner(ch); partner != "" {
+			text = "@" + m.nick(partner)
+			if status := m.statuses[partner]; status != "" {
+				text += " (" + status + ")"
+			}
+		} else if header := strings.Join(strings.Fields(ch.Header), " "); header != "" {
+			text += " — " + header
+		}
+	}
+	return style.status.Render(padRight(fitWidth(" "+text, mainWidth), mainWidth))
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"fmt"
+
+	comm "libcommunicator"
+)
+
+// version is termunicator's release, set when building a release with
+//
+//	go build -ldflags "-X main.version=v1.2.0"
+var version = "dev"
+
+// versionString returns termunicator's version and the library's, for
+// -version, the help overlay and bug reports
+func versionString() string {
+	return fmt.Sprintf("termunicator %s (libcommunicator %s)", version, comm.GetVersion())
+}